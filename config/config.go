@@ -0,0 +1,288 @@
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// Config 应用配置，来源于配置文件和环境变量（环境变量优先）
+type Config struct {
+    SourceRoots    []string                 `json:"sourceRoots"`    // 允许作为源目录的根路径
+    TargetRoots    []string                 `json:"targetRoots"`    // 允许作为目标目录的根路径
+    TrustedProxies []string                 `json:"trustedProxies"` // 受信任的反向代理地址
+    Workers        int                      `json:"workers"`        // 异步任务的并发数
+    QueueSize      int                      `json:"queueSize"`      // 任务队列长度
+    DataDir        string                   `json:"dataDir"`        // 数据目录，保存任务历史等持久化数据
+    HistoryLimit   int                      `json:"historyLimit"`   // 任务历史保留的任务数，0 表示不限制
+    Auth           AuthConfig               `json:"auth"`
+    Watch          WatchConfig              `json:"watch"`
+    Profiles       map[string]ProfileConfig `json:"profiles"` // 处理配置，供下载器回调等自动处理使用
+    Hooks          HooksConfig              `json:"hooks"`
+    QBittorrent    QBittorrentConfig        `json:"qbittorrent"`
+    MediaServers   []MediaServerConfig      `json:"mediaServers"` // 任务成功后通知刷新媒体库
+    Webhooks       []WebhookConfig          `json:"webhooks"`     // 任务事件通知
+    PublicURL      string                   `json:"publicUrl"`    // 本服务的外部访问地址，用于通知中的任务链接
+    PathMaps       map[string][]PathMapping `json:"pathMaps"`     // 创建符号链接时改写链接目标的路径映射，按名称选择
+    StrictLinks    bool                     `json:"strictLinks"`  // 拒绝创建无法解析回源文件的链接
+    Xattr          bool                     `json:"xattr"`        // 在移动到媒体库的文件上写入 user.vdsymlink.* 扩展属性
+    Naming         string                   `json:"naming"`       // 命名模板：default（S01/剧集名.S01E01）或 jellyfin（Season 01/剧集名 S01E01）
+}
+
+// AuthConfig 认证配置，未配置任何用户时不启用认证
+type AuthConfig struct {
+    Users        []UserConfig          `json:"users"`
+    Tokens       []TokenConfig         `json:"tokens"`
+    Roles        map[string]RoleConfig `json:"roles"`
+    DefaultRole  string                `json:"defaultRole"`  // 未指定角色的用户（包括代理用户）使用的角色
+    ProxyHeader  string                `json:"proxyHeader"`  // 信任反向代理传入的用户名请求头，如 Remote-User
+    SessionHours int                   `json:"sessionHours"` // 登录会话有效期（小时）
+}
+
+// UserConfig 用户配置，密码使用 bcrypt 哈希保存
+type UserConfig struct {
+    Username     string `json:"username"`
+    PasswordHash string `json:"passwordHash"`
+    Role         string `json:"role"`
+    Password     string `json:"-"` // 来自环境变量的明文密码，启动时哈希
+}
+
+// TokenConfig API令牌配置，令牌使用 SHA-256 哈希（十六进制）保存
+type TokenConfig struct {
+    Name      string `json:"name"`
+    User      string `json:"user"`
+    Role      string `json:"role"` // 为空时使用所属用户的角色
+    TokenHash string `json:"tokenHash"`
+    Token     string `json:"-"` // 来自环境变量的明文令牌
+}
+
+// RoleConfig 角色权限，为空的字段表示不做额外限制
+type RoleConfig struct {
+    Modes       []string `json:"modes"`       // 允许的处理模式：link, move, rename, migrate，为空时允许所有模式
+    SourceRoots []string `json:"sourceRoots"` // 允许的源目录根路径
+    TargetRoots []string `json:"targetRoots"` // 允许的目标目录根路径
+}
+
+// WatchConfig 监视目录配置，下载完成的剧集自动处理
+type WatchConfig struct {
+    Rules         []WatchRule `json:"rules"`
+    PollSeconds   int         `json:"pollSeconds"`   // 轮询间隔（秒），inotify 可用时作为兜底扫描间隔
+    StableSeconds int         `json:"stableSeconds"` // 文件大小保持不变多久后视为下载完成（秒）
+    Polling       bool        `json:"polling"`       // 强制使用轮询（如网络文件系统不支持 inotify）
+}
+
+// WatchRule 监视规则：源根目录下每个剧集目录下载完成后处理到目标目录
+type WatchRule struct {
+    Name            string `json:"name"`
+    SourceRoot      string `json:"sourceRoot"`      // 监视的下载目录，其中每个子目录视为一个剧集
+    TargetDir       string `json:"targetDir"`       // 目标媒体库目录
+    Mode            string `json:"mode"`            // link 或 move，默认 link
+    RedirectPath    string `json:"redirectPath"`    // 符号链接重定向路径
+    PathMap         string `json:"pathMap"`         // 路径映射名称
+    Sync            bool   `json:"sync"`            // 同步目标季目录，删除源文件已不存在的链接
+    Naming          string `json:"naming"`          // 命名模板，为空时使用全局 naming
+    StableSeconds   int    `json:"stableSeconds"`   // 覆盖全局的稳定时间
+    ProcessExisting bool   `json:"processExisting"` // 首次启动时是否处理已存在的目录
+}
+
+// ProfileConfig 处理配置：按下载分类选择目标目录和处理模式
+type ProfileConfig struct {
+    TargetDir    string   `json:"targetDir"`
+    Mode         string   `json:"mode"` // link 或 move，默认 link
+    RedirectPath string   `json:"redirectPath"`
+    PathMap      string   `json:"pathMap"`    // 路径映射名称
+    Sync         bool     `json:"sync"`       // 同步目标季目录，删除源文件已不存在的链接
+    Naming       string   `json:"naming"`     // 命名模板，为空时使用全局 naming
+    Categories   []string `json:"categories"` // 使用该配置的下载分类，名为 default 的配置用于未匹配的分类
+}
+
+// HooksConfig 下载器回调配置，未设置密钥时不启用回调接口
+type HooksConfig struct {
+    Secret string `json:"secret"`
+}
+
+// QBittorrentConfig 定期从 qBittorrent WebUI 拉取已完成的种子并处理，未设置 URL 时不启用
+type QBittorrentConfig struct {
+    URL             string        `json:"url"` // WebUI 地址，如 http://qbittorrent:8080
+    Username        string        `json:"username"`
+    Password        string        `json:"password"`
+    Categories      []string      `json:"categories"`      // 处理的分类，为空时使用所有处理配置中的分类
+    PollSeconds     int           `json:"pollSeconds"`     // 拉取间隔（秒）
+    PathMappings    []PathMapping `json:"pathMappings"`    // qBittorrent 中的路径到本服务中路径的映射
+    Tag             string        `json:"tag"`             // 处理成功后为种子添加的标签，为空时不添加
+    ProcessExisting bool          `json:"processExisting"` // 首次启用时是否处理已完成的种子
+}
+
+// MediaServerConfig 媒体服务器配置
+type MediaServerConfig struct {
+    Name         string        `json:"name"`
+    Type         string        `json:"type"` // jellyfin、emby 或 plex
+    URL          string        `json:"url"`
+    Token        string        `json:"token"`        // Jellyfin/Emby 的 API 密钥或 Plex 的 X-Plex-Token
+    LibraryID    string        `json:"libraryId"`    // Plex 媒体库ID，为空时按路径自动查找
+    PathMappings []PathMapping `json:"pathMappings"` // 本服务中的路径到媒体服务器中路径的映射
+    Retries      int           `json:"retries"`      // 失败后的重试次数，默认 3
+}
+
+// WebhookConfig 任务事件的 HTTP 通知
+type WebhookConfig struct {
+    Name        string            `json:"name"`
+    URL         string            `json:"url"`
+    Events      []string          `json:"events"`      // started、succeeded、partial、failed、cancelled，为空时全部发送
+    Preset      string            `json:"preset"`      // 聊天机器人格式：discord、slack、telegram、feishu、dingtalk、wecom
+    Template    string            `json:"template"`    // text/template 模板；使用预设时为消息文本，否则为完整请求体
+    ContentType string            `json:"contentType"` // 默认 application/json
+    Secret      string            `json:"secret"`      // HMAC-SHA256 签名密钥
+    Headers     map[string]string `json:"headers"`
+}
+
+// PathMapping 路径前缀映射
+type PathMapping struct {
+    From string `json:"from"`
+    To   string `json:"to"`
+}
+
+// 未配置 trustedProxies 时解析客户端IP所信任的内网代理，不用于信任用户名和协议请求头
+var defaultTrustedProxies = []string{"127.0.0.1", "192.168.0.0/16", "10.0.0.0/8", "172.16.0.0/12"}
+
+// Load 加载配置
+func Load() (*Config, error) {
+    cfg := &Config{
+        Workers:      2,
+        QueueSize:    100,
+        DataDir:      "data",
+        HistoryLimit: 5000,
+        Watch: WatchConfig{
+            PollSeconds:   60,
+            StableSeconds: 60,
+        },
+        QBittorrent: QBittorrentConfig{
+            PollSeconds: 60,
+        },
+        Auth: AuthConfig{
+            SessionHours: 24 * 7,
+        },
+    }
+
+    if err := cfg.loadFile(); err != nil {
+        return nil, err
+    }
+    cfg.loadEnv()
+
+    // 任何能连接到服务的客户端都能伪造用户名请求头，只信任明确配置的代理
+    if cfg.Auth.ProxyHeader != "" && len(cfg.TrustedProxies) == 0 {
+        return nil, fmt.Errorf("启用 proxyHeader 时必须在 trustedProxies（或 VD_TRUSTED_PROXIES）中明确配置反向代理的地址")
+    }
+
+    return cfg, nil
+}
+
+// ClientIPProxies 解析客户端IP时信任的代理，未配置 trustedProxies 时使用常用内网地址
+func (c *Config) ClientIPProxies() []string {
+    if len(c.TrustedProxies) > 0 {
+        return c.TrustedProxies
+    }
+    return defaultTrustedProxies
+}
+
+// loadFile 从配置文件加载，未指定且默认文件不存在时跳过
+func (c *Config) loadFile() error {
+    path := os.Getenv("VD_CONFIG")
+    explicit := path != ""
+    if !explicit {
+        path = "config.json"
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) && !explicit {
+            return nil
+        }
+        return fmt.Errorf("无法读取配置文件 %s: %v", path, err)
+    }
+
+    if err := json.Unmarshal(data, c); err != nil {
+        return fmt.Errorf("配置文件格式错误 %s: %v", path, err)
+    }
+    return nil
+}
+
+// loadEnv 从环境变量加载，多个路径使用系统路径分隔符（Linux下为 :）分隔
+func (c *Config) loadEnv() {
+    if roots := os.Getenv("VD_SOURCE_ROOTS"); roots != "" {
+        c.SourceRoots = splitList(roots)
+    }
+    if roots := os.Getenv("VD_TARGET_ROOTS"); roots != "" {
+        c.TargetRoots = splitList(roots)
+    }
+    if workers, err := strconv.Atoi(os.Getenv("VD_WORKERS")); err == nil && workers > 0 {
+        c.Workers = workers
+    }
+    if secret := os.Getenv("VD_HOOK_SECRET"); secret != "" {
+        c.Hooks.Secret = secret
+    }
+    if url := os.Getenv("VD_QBIT_URL"); url != "" {
+        c.QBittorrent.URL = url
+    }
+    if username := os.Getenv("VD_QBIT_USERNAME"); username != "" {
+        c.QBittorrent.Username = username
+    }
+    if password := os.Getenv("VD_QBIT_PASSWORD"); password != "" {
+        c.QBittorrent.Password = password
+    }
+    if publicURL := os.Getenv("VD_PUBLIC_URL"); publicURL != "" {
+        c.PublicURL = publicURL
+    }
+    if dir := os.Getenv("VD_DATA_DIR"); dir != "" {
+        c.DataDir = dir
+    }
+    if proxies := os.Getenv("VD_TRUSTED_PROXIES"); proxies != "" {
+        c.TrustedProxies = splitComma(proxies)
+    }
+
+    // 环境变量中的用户，密码可以是明文或 bcrypt 哈希
+    username := os.Getenv("VD_AUTH_USERNAME")
+    if username != "" {
+        c.Auth.Users = append(c.Auth.Users, UserConfig{
+            Username:     username,
+            PasswordHash: os.Getenv("VD_AUTH_PASSWORD_HASH"),
+            Password:     os.Getenv("VD_AUTH_PASSWORD"),
+            Role:         os.Getenv("VD_AUTH_ROLE"),
+        })
+    }
+    for i, token := range splitComma(os.Getenv("VD_API_TOKENS")) {
+        c.Auth.Tokens = append(c.Auth.Tokens, TokenConfig{
+            Name:  fmt.Sprintf("env-%d", i+1),
+            User:  username,
+            Token: token,
+        })
+    }
+    if header := os.Getenv("VD_AUTH_PROXY_HEADER"); header != "" {
+        c.Auth.ProxyHeader = header
+    }
+}
+
+// splitComma 拆分逗号分隔的列表并去除空项
+func splitComma(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// splitList 拆分路径列表并去除空项
+func splitList(value string) []string {
+    var items []string
+    for _, item := range filepath.SplitList(value) {
+        if item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+const (
+    sessionCookie    = "vd_session"
+    userContextKey   = "user"
+    secureContextKey = "secure" // 请求通过 HTTPS 到达（直接或经受信任的代理）
+)
+
+type AuthHandler struct {
+    auth *services.AuthService
+    csrf *services.CSRFService
+}
+
+func NewAuthHandler(auth *services.AuthService, csrf *services.CSRFService) *AuthHandler {
+    return &AuthHandler{
+        auth: auth,
+        csrf: csrf,
+    }
+}
+
+// RequireAuth 认证中间件：依次尝试反向代理请求头、Bearer令牌和会话Cookie
+func (h *AuthHandler) RequireAuth() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !h.auth.Enabled() {
+            c.Next()
+            return
+        }
+
+        if user, ok := h.authenticate(c); ok {
+            c.Set(userContextKey, user)
+            c.Next()
+            return
+        }
+
+        if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+            logDenied(c, "", fmt.Errorf("未认证"))
+            c.AbortWithStatusJSON(http.StatusUnauthorized, models.ProcessResponse{
+                Success: false,
+                Message: "未登录或令牌无效",
+            })
+            return
+        }
+
+        c.Redirect(http.StatusSeeOther, "/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+        c.Abort()
+    }
+}
+
+// DetectHTTPS 判断请求是否通过 HTTPS 到达，仅信任受信任代理传入的 X-Forwarded-Proto
+func (h *AuthHandler) DetectHTTPS() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        secure := c.Request.TLS != nil ||
+            (c.GetHeader("X-Forwarded-Proto") == "https" && h.auth.IsTrustedProxy(c.RemoteIP()))
+        c.Set(secureContextKey, secure)
+        c.Next()
+    }
+}
+
+func (h *AuthHandler) authenticate(c *gin.Context) (*services.User, bool) {
+    // 仅信任来自受信任代理的用户名请求头
+    if header := h.auth.ProxyHeader(); header != "" {
+        if username := c.GetHeader(header); username != "" && h.auth.IsTrustedProxy(c.RemoteIP()) {
+            return h.auth.ProxyUser(username), true
+        }
+    }
+
+    if authorization := c.GetHeader("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+        return h.auth.AuthenticateToken(strings.TrimPrefix(authorization, "Bearer "))
+    }
+
+    if id, err := c.Cookie(sessionCookie); err == nil {
+        return h.auth.Session(id)
+    }
+
+    return nil, false
+}
+
+// GetLogin 显示登录页
+func (h *AuthHandler) GetLogin(c *gin.Context) {
+    c.HTML(http.StatusOK, "login.html", gin.H{
+        "title":     "VdSYMLinkTool - 登录",
+        "next":      safeNext(c.Query("next")),
+        "csrfToken": csrfToken(c, h.csrf),
+    })
+}
+
+// PostLogin 处理登录表单
+func (h *AuthHandler) PostLogin(c *gin.Context) {
+    username := c.PostForm("username")
+    next := safeNext(c.PostForm("next"))
+
+    if !verifyCSRF(c, h.csrf) {
+        logDenied(c, "username="+username, fmt.Errorf("CSRF令牌无效"))
+        c.HTML(http.StatusForbidden, "login.html", gin.H{
+            "title":     "VdSYMLinkTool - 登录",
+            "error":     "页面已过期，请重新登录",
+            "username":  username,
+            "next":      next,
+            "csrfToken": csrfToken(c, h.csrf),
+        })
+        return
+    }
+
+    id, err := h.auth.Login(username, c.PostForm("password"))
+    if err != nil {
+        logDenied(c, "username="+username, err)
+        c.HTML(http.StatusUnauthorized, "login.html", gin.H{
+            "title":     "VdSYMLinkTool - 登录",
+            "error":     err.Error(),
+            "username":  username,
+            "next":      next,
+            "csrfToken": csrfToken(c, h.csrf),
+        })
+        return
+    }
+
+    setCookie(c, sessionCookie, id, int(h.auth.SessionTTL().Seconds()), http.SameSiteLaxMode)
+    c.Redirect(http.StatusSeeOther, next)
+}
+
+// Logout 退出登录
+func (h *AuthHandler) Logout(c *gin.Context) {
+    if !verifyCSRF(c, h.csrf) {
+        logDenied(c, "", fmt.Errorf("CSRF令牌无效"))
+        c.Redirect(http.StatusSeeOther, "/")
+        return
+    }
+    if id, err := c.Cookie(sessionCookie); err == nil {
+        h.auth.Logout(id)
+    }
+    setCookie(c, sessionCookie, "", -1, http.SameSiteLaxMode)
+    c.Redirect(http.StatusSeeOther, "/login")
+}
+
+// setCookie 设置仅HTTP访问的Cookie，HTTPS请求时附加Secure
+func setCookie(c *gin.Context, name, value string, maxAge int, sameSite http.SameSite) {
+    secure := c.Request.TLS != nil || c.GetBool(secureContextKey)
+    c.SetSameSite(sameSite)
+    c.SetCookie(name, value, maxAge, "/", "", secure, true)
+}
+
+// safeNext 只允许站内跳转，避免开放重定向
+func safeNext(next string) string {
+    if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+        return "/"
+    }
+    return next
+}
+
+// currentUser 获取当前请求的用户，未启用认证时返回 nil
+func currentUser(c *gin.Context) *services.User {
+    if value, ok := c.Get(userContextKey); ok {
+        if user, ok := value.(*services.User); ok {
+            return user
+        }
+    }
+    return nil
+}
+
+// logDenied 记录被拒绝的请求
+func logDenied(c *gin.Context, detail string, err error) {
+    username := "-"
+    if user := currentUser(c); user != nil {
+        username = user.Username
+    }
+    fmt.Printf("🚫 拒绝请求: user=%s ip=%s %s %s %s: %v\n",
+        username, c.ClientIP(), c.Request.Method, c.Request.URL.Path, detail, err)
+}
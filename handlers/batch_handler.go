@@ -0,0 +1,230 @@
+package handlers
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// 批量处理的限制
+const (
+    maxBatchItems    = 500
+    maxBatchParallel = 8
+    maxBatchDuration = 30 * time.Minute // 超过后不再开始新的请求，已开始的请求继续完成
+)
+
+// NDJSON 请求体类型，每行一个处理请求
+var contentTypesNDJSON = map[string]bool{
+    "application/x-ndjson": true,
+    "application/ndjson":   true,
+    "application/jsonl":    true,
+}
+
+// batchItem 已校验的批量请求
+type batchItem struct {
+    result *models.BatchItemResult
+    opts   services.ProcessOptions
+}
+
+// ProcessBatch 批量处理：请求体为处理请求的 JSON 数组或 NDJSON，按顺序或有限并发同步执行，返回每个请求的结果
+// 查询参数 parallel 为并发数（默认 1），stopOnError=true 时任一请求失败后不再执行未开始的请求
+// 任务锁定源目录和目标目录，目标目录相同的请求（如同一媒体库的多个季）仍依次执行，并发只对不同的目标目录有效
+func (h *SymlinkHandler) ProcessBatch(c *gin.Context) {
+    contentType := mediaType(c)
+    if contentType != contentTypeJSON && !contentTypesNDJSON[contentType] {
+        c.JSON(http.StatusUnsupportedMediaType, models.ProcessResponse{
+            Success: false,
+            Message: "不支持的Content-Type，请使用 application/json 或 application/x-ndjson",
+        })
+        return
+    }
+    parallel := 1
+    if value := c.Query("parallel"); value != "" {
+        n, err := strconv.Atoi(value)
+        if err != nil || n < 1 || n > maxBatchParallel {
+            c.JSON(http.StatusBadRequest, models.ProcessResponse{
+                Success: false,
+                Message: fmt.Sprintf("parallel 应为 1 到 %d 之间的整数", maxBatchParallel),
+            })
+            return
+        }
+        parallel = n
+    }
+    stopOnError := c.Query("stopOnError") == "true"
+
+    requests, err := decodeBatch(c.Request.Body)
+    if err == nil && len(requests) == 0 {
+        err = fmt.Errorf("没有处理请求")
+    }
+    if err == nil && len(requests) > maxBatchItems {
+        err = fmt.Errorf("一次最多提交 %d 个处理请求", maxBatchItems)
+    }
+    if err != nil {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "请求参数错误: " + err.Error(),
+        })
+        return
+    }
+
+    // 先校验所有请求，指定 stopOnError 时有错误的请求则全部不执行
+    results := make([]models.BatchItemResult, len(requests))
+    var items []batchItem
+    invalid := false
+    for i, req := range requests {
+        results[i] = models.BatchItemResult{Index: i}
+        // 批量请求总是等待执行完成
+        req.Async = false
+        if req.Mode == "" {
+            results[i].Request = req
+            results[i].Status = models.BatchInvalid
+            results[i].StatusCode = http.StatusBadRequest
+            results[i].Error = "处理模式 mode 不能为空"
+            invalid = true
+            continue
+        }
+        opts, status, err := h.prepare(c, &req)
+        results[i].Request = req
+        if err != nil {
+            results[i].Status = models.BatchInvalid
+            results[i].StatusCode = status
+            results[i].Error = err.Error()
+            invalid = true
+            continue
+        }
+        items = append(items, batchItem{result: &results[i], opts: opts})
+    }
+    if invalid && stopOnError {
+        for _, item := range items {
+            item.result.Status = models.BatchSkipped
+        }
+        items = nil
+    }
+
+    h.runBatch(c, items, parallel, stopOnError)
+    c.JSON(http.StatusOK, batchResponse(results))
+}
+
+// runBatch 使用 parallel 个并发执行请求，目录正被其他任务使用时等待其结束
+// 开始执行超过 maxBatchDuration 后不再开始新的请求
+func (h *SymlinkHandler) runBatch(c *gin.Context, items []batchItem, parallel int, stopOnError bool) {
+    user := currentUser(c)
+    ctx := c.Request.Context()
+    deadline := time.Now().Add(maxBatchDuration)
+
+    var mu sync.Mutex
+    stopped := false
+    next := make(chan batchItem)
+    var wg sync.WaitGroup
+    for i := 0; i < parallel; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for item := range next {
+                mu.Lock()
+                skip := stopped
+                mu.Unlock()
+                if skip || ctx.Err() != nil {
+                    item.result.Status = models.BatchSkipped
+                    continue
+                }
+                if time.Now().After(deadline) {
+                    item.result.Status = models.BatchSkipped
+                    item.result.Error = fmt.Sprintf("批量处理已超过 %v，未执行", maxBatchDuration)
+                    continue
+                }
+
+                job := h.jobs.RunWait(ctx, item.result.Request, user, item.opts)
+                item.result.JobID = job.ID
+                item.result.Status = job.Status
+                item.result.Error = job.Error
+                item.result.Result = job.Result
+                if job.Status != models.JobSucceeded && stopOnError {
+                    mu.Lock()
+                    stopped = true
+                    mu.Unlock()
+                }
+            }
+        }()
+    }
+    for _, item := range items {
+        next <- item
+    }
+    close(next)
+    wg.Wait()
+}
+
+// batchResponse 汇总每个请求的结果
+func batchResponse(results []models.BatchItemResult) models.BatchResponse {
+    response := models.BatchResponse{Total: len(results), Items: results}
+    for _, result := range results {
+        switch result.Status {
+        case models.JobSucceeded:
+            response.Succeeded++
+        case models.BatchSkipped:
+            response.Skipped++
+        default:
+            response.Failed++
+        }
+    }
+    switch {
+    case response.Succeeded == response.Total:
+        response.Status = models.BatchSucceeded
+    case response.Succeeded > 0:
+        response.Status = models.BatchPartial
+    default:
+        response.Status = models.BatchFailed
+    }
+    response.Success = response.Status == models.BatchSucceeded
+    return response
+}
+
+// decodeBatch 解析 JSON 数组或每行一个 JSON 对象的 NDJSON
+func decodeBatch(body io.Reader) ([]models.ProcessRequest, error) {
+    reader := bufio.NewReader(body)
+    for {
+        b, err := reader.ReadByte()
+        if err == io.EOF {
+            return nil, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        if bytes.ContainsRune([]byte(" \t\r\n"), rune(b)) {
+            continue
+        }
+        reader.UnreadByte()
+        if b == '[' {
+            var requests []models.ProcessRequest
+            if err := json.NewDecoder(reader).Decode(&requests); err != nil {
+                return nil, err
+            }
+            return requests, nil
+        }
+        break
+    }
+
+    var requests []models.ProcessRequest
+    decoder := json.NewDecoder(reader)
+    for {
+        var req models.ProcessRequest
+        err := decoder.Decode(&req)
+        if err == io.EOF {
+            return requests, nil
+        }
+        if err != nil {
+            return nil, fmt.Errorf("第 %d 个请求: %v", len(requests)+1, err)
+        }
+        requests = append(requests, req)
+    }
+}
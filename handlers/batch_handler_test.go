@@ -0,0 +1,176 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// batchEnv 批量处理测试用的源目录、目标目录和路由
+type batchEnv struct {
+    source string
+    target string
+    router *gin.Engine
+}
+
+func newBatchEnv(t *testing.T) *batchEnv {
+    t.Helper()
+    dir := t.TempDir()
+    env := &batchEnv{source: filepath.Join(dir, "src"), target: filepath.Join(dir, "lib")}
+    for _, path := range []string{filepath.Join(env.source, "Empty"), env.target} {
+        if err := os.MkdirAll(path, 0755); err != nil {
+            t.Fatal(err)
+        }
+    }
+    for _, show := range []string{"ShowA", "ShowB", "ShowC", "ShowD"} {
+        for _, episode := range []string{"E01", "E02"} {
+            path := filepath.Join(env.source, show, show+"."+episode+".mkv")
+            if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+                t.Fatal(err)
+            }
+            if err := os.WriteFile(path, []byte(show), 0644); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+
+    guard := services.NewPathGuard([]string{env.source}, []string{env.target})
+    mapper, err := services.NewPathMapper(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    history, err := services.NewHistoryStore(filepath.Join(dir, "data"), 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { history.Close() })
+    naming, err := services.LookupNaming("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    symlink := services.NewSymlinkService(mapper, services.NewManifestStore(history, false), naming, false)
+    jobs := services.NewJobManager(symlink, services.NewJobStore(), history, 2, 10)
+    permissions, err := services.NewPermissionService(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    csrf, err := services.NewCSRFService()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    gin.SetMode(gin.TestMode)
+    env.router = gin.New()
+    env.router.POST("/api/process/batch", NewSymlinkHandler(jobs, guard, mapper, permissions, csrf).ProcessBatch)
+    return env
+}
+
+// request 生成处理源目录下剧集文件夹的链接请求
+func (env *batchEnv) request(show, target string) models.ProcessRequest {
+    return models.ProcessRequest{
+        SourceDir: filepath.Join(env.source, show),
+        TargetDir: filepath.Join(env.target, target),
+        Mode:      "link",
+    }
+}
+
+func (env *batchEnv) post(t *testing.T, query string, requests []models.ProcessRequest) (int, models.BatchResponse) {
+    t.Helper()
+    body, err := json.Marshal(requests)
+    if err != nil {
+        t.Fatal(err)
+    }
+    req := httptest.NewRequest(http.MethodPost, "/api/process/batch"+query, strings.NewReader(string(body)))
+    req.Header.Set("Content-Type", "application/json")
+    recorder := httptest.NewRecorder()
+    env.router.ServeHTTP(recorder, req)
+
+    var response models.BatchResponse
+    if recorder.Code == http.StatusOK {
+        if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return recorder.Code, response
+}
+
+func statuses(response models.BatchResponse) []string {
+    var result []string
+    for _, item := range response.Items {
+        result = append(result, item.Status)
+    }
+    return result
+}
+
+func TestBatchStopOnErrorSkipsEverythingWhenARequestIsInvalid(t *testing.T) {
+    env := newBatchEnv(t)
+    outside := models.ProcessRequest{SourceDir: t.TempDir(), TargetDir: env.target, Mode: "link"}
+    _, response := env.post(t, "?stopOnError=true", []models.ProcessRequest{env.request("ShowA", "a"), outside})
+
+    want := []string{models.BatchSkipped, models.BatchInvalid}
+    if got := statuses(response); strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Fatalf("状态 %v，期望 %v", got, want)
+    }
+    if response.Items[1].StatusCode != http.StatusForbidden || response.Status != models.BatchFailed {
+        t.Fatalf("根目录外的请求应返回 403，批量状态为 failed: %+v", response)
+    }
+    if entries, _ := os.ReadDir(env.target); len(entries) != 0 {
+        t.Fatal("有无效请求时不应执行任何请求")
+    }
+}
+
+func TestBatchStopOnErrorSkipsRemainingAfterAFailure(t *testing.T) {
+    env := newBatchEnv(t)
+    requests := []models.ProcessRequest{env.request("ShowA", "a"), env.request("Empty", "a"), env.request("ShowB", "a")}
+
+    _, response := env.post(t, "?stopOnError=true", requests)
+    want := []string{models.JobSucceeded, models.JobFailed, models.BatchSkipped}
+    if got := statuses(response); strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Fatalf("状态 %v，期望 %v", got, want)
+    }
+    if response.Status != models.BatchPartial || response.Skipped != 1 {
+        t.Fatalf("批量状态 %s，跳过 %d 个", response.Status, response.Skipped)
+    }
+
+    // 未指定 stopOnError 时失败的请求不影响其他请求
+    _, response = env.post(t, "", requests)
+    want = []string{models.JobSucceeded, models.JobFailed, models.JobSucceeded}
+    if got := statuses(response); strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Fatalf("状态 %v，期望 %v", got, want)
+    }
+}
+
+func TestBatchParallel(t *testing.T) {
+    env := newBatchEnv(t)
+    // 两个目标目录各两个请求，同一目标目录的请求依次执行
+    requests := []models.ProcessRequest{
+        env.request("ShowA", "a"), env.request("ShowB", "b"),
+        env.request("ShowC", "a"), env.request("ShowD", "b"),
+    }
+    _, response := env.post(t, "?parallel=2", requests)
+    if response.Status != models.BatchSucceeded || response.Succeeded != len(requests) {
+        t.Fatalf("并发执行的请求应全部成功: %v", statuses(response))
+    }
+    for i, item := range response.Items {
+        if item.Index != i || item.Request.SourceDir != requests[i].SourceDir || item.JobID == "" {
+            t.Fatalf("结果应按请求顺序排列: %+v", item)
+        }
+        if item.Result == nil || item.Result.Processed != 2 {
+            t.Fatalf("请求 %d 应处理 2 个文件: %+v", i, item.Result)
+        }
+    }
+
+    for _, query := range []string{"?parallel=0", "?parallel=9", "?parallel=x"} {
+        if code, _ := env.post(t, query, requests); code != http.StatusBadRequest {
+            t.Errorf("%s 应返回 400，实际 %d", query, code)
+        }
+    }
+}
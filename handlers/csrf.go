@@ -0,0 +1,66 @@
+package handlers
+
+import (
+    "mime"
+    "net/http"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+const (
+    csrfCookie = "vd_csrf"
+    csrfField  = "csrfToken"
+    csrfHeader = "X-CSRF-Token"
+)
+
+// 请求体类型
+const (
+    contentTypeForm = "application/x-www-form-urlencoded"
+    contentTypeJSON = "application/json"
+)
+
+// csrfToken 获取当前浏览器的CSRF令牌，必要时下发新的标识Cookie
+func csrfToken(c *gin.Context, csrf *services.CSRFService) string {
+    binding, err := c.Cookie(csrfCookie)
+    if err != nil || binding == "" {
+        if binding, err = csrf.NewBinding(); err != nil {
+            return ""
+        }
+        setCookie(c, csrfCookie, binding, 0, http.SameSiteStrictMode)
+    }
+    return csrf.Token(binding)
+}
+
+// verifyCSRF 校验表单或请求头中的CSRF令牌
+func verifyCSRF(c *gin.Context, csrf *services.CSRFService) bool {
+    binding, _ := c.Cookie(csrfCookie)
+    token := c.PostForm(csrfField)
+    if token == "" {
+        token = c.GetHeader(csrfHeader)
+    }
+    return csrf.Verify(binding, token)
+}
+
+// mediaType 解析请求的Content-Type，忽略 charset 等参数
+func mediaType(c *gin.Context) string {
+    mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+    if err != nil {
+        return ""
+    }
+    return mediaType
+}
+
+// requireJSON 只接受 application/json 请求体，其他类型返回 415
+// 浏览器跨站提交的表单只能使用表单或 text/plain 类型，不能伪造 JSON 请求
+func requireJSON(c *gin.Context) bool {
+    if mediaType(c) == contentTypeJSON {
+        return true
+    }
+    c.JSON(http.StatusUnsupportedMediaType, models.ProcessResponse{
+        Success: false,
+        Message: "不支持的Content-Type，请使用 application/json",
+    })
+    return false
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+    "crypto/subtle"
+    "fmt"
+    "net/http"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+const hookSecretHeader = "X-Hook-Secret"
+
+type HookHandler struct {
+    jobs     *services.JobManager
+    guard    *services.PathGuard
+    profiles *services.ProfileService
+    secret   string
+}
+
+func NewHookHandler(jobs *services.JobManager, guard *services.PathGuard, profiles *services.ProfileService, secret string) *HookHandler {
+    return &HookHandler{
+        jobs:     jobs,
+        guard:    guard,
+        profiles: profiles,
+        secret:   secret,
+    }
+}
+
+// TorrentComplete 下载器回调：按分类选择处理配置并将内容路径（目录或单个视频文件）加入处理队列
+func (h *HookHandler) TorrentComplete(c *gin.Context) {
+    if h.secret == "" {
+        c.JSON(http.StatusNotFound, models.ProcessResponse{
+            Success: false,
+            Message: "回调接口未启用，请配置 hooks.secret 或 VD_HOOK_SECRET",
+        })
+        return
+    }
+
+    // 密钥可以放在请求头或表单中；查询参数会出现在访问日志里，不接受
+    secret := c.GetHeader(hookSecretHeader)
+    if secret == "" {
+        secret = c.PostForm("secret")
+    }
+    if subtle.ConstantTimeCompare([]byte(secret), []byte(h.secret)) != 1 {
+        logDenied(c, "hook torrent-complete", fmt.Errorf("回调密钥无效"))
+        h.respond(c, http.StatusUnauthorized, "回调密钥无效")
+        return
+    }
+
+    var req models.TorrentHookRequest
+    switch mediaType(c) {
+    case contentTypeJSON:
+        if err := c.ShouldBindJSON(&req); err != nil {
+            h.respond(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+            return
+        }
+    case contentTypeForm, "multipart/form-data":
+        if err := c.ShouldBind(&req); err != nil {
+            h.respond(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+            return
+        }
+    default:
+        c.JSON(http.StatusUnsupportedMediaType, models.ProcessResponse{
+            Success: false,
+            Message: "不支持的Content-Type，请使用 application/json 或 application/x-www-form-urlencoded",
+        })
+        return
+    }
+
+    if req.ContentPath == "" {
+        h.respond(c, http.StatusBadRequest, "contentPath 不能为空")
+        return
+    }
+
+    profile, err := h.profiles.ForCategory(req.Category)
+    if err != nil {
+        h.respond(c, http.StatusUnprocessableEntity, err.Error())
+        return
+    }
+
+    sourceDir, err := h.guard.Resolve(req.ContentPath, services.ScopeSource)
+    if err != nil {
+        logDenied(c, "hook contentPath="+req.ContentPath, err)
+        h.respond(c, http.StatusForbidden, err.Error())
+        return
+    }
+    // 单文件种子的内容路径为视频文件，只处理该文件
+    if err := services.CheckSourcePath(sourceDir); err != nil {
+        h.respond(c, http.StatusUnprocessableEntity, err.Error())
+        return
+    }
+
+    processReq, opts := profile.Request(sourceDir)
+    user := &services.User{Username: "hook:" + profile.Name, Method: "hook"}
+    job, err := h.jobs.Enqueue(processReq, user, opts)
+    if err != nil {
+        h.respond(c, http.StatusServiceUnavailable, err.Error())
+        return
+    }
+
+    fmt.Printf("📥 下载完成回调: %s (分类 %q，配置 %s) -> 任务 %s\n", req.Name, req.Category, profile.Name, job.ID)
+    c.JSON(http.StatusAccepted, models.ProcessResponse{
+        Success: true,
+        Message: "任务已加入队列",
+        Data:    job,
+        JobID:   job.ID,
+    })
+}
+
+// respond 返回错误JSON
+func (h *HookHandler) respond(c *gin.Context, status int, message string) {
+    c.JSON(status, models.ProcessResponse{
+        Success: false,
+        Message: message,
+    })
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+type ImportHandler struct {
+    imports     *services.ImportService
+    guard       *services.PathGuard
+    mapper      *services.PathMapper
+    permissions *services.PermissionService
+    csrf        *services.CSRFService
+}
+
+func NewImportHandler(imports *services.ImportService, guard *services.PathGuard, mapper *services.PathMapper, permissions *services.PermissionService, csrf *services.CSRFService) *ImportHandler {
+    return &ImportHandler{
+        imports:     imports,
+        guard:       guard,
+        mapper:      mapper,
+        permissions: permissions,
+        csrf:        csrf,
+    }
+}
+
+// GetImport 显示批量导入页面，填写下载目录和目标目录后列出候选文件夹
+func (h *ImportHandler) GetImport(c *gin.Context) {
+    req := importRequestFromQuery(c)
+    data := gin.H{"req": req}
+    if req.Root == "" || req.TargetDir == "" {
+        h.renderImport(c, http.StatusOK, data)
+        return
+    }
+
+    plan, status, err := h.plan(c, &req)
+    if err != nil {
+        data["error"] = err.Error()
+        h.renderImport(c, status, data)
+        return
+    }
+    data["plan"] = plan
+    h.renderImport(c, http.StatusOK, data)
+}
+
+// PostImport 从页面提交选中的候选文件夹，创建批量导入后跳转到报告页面
+func (h *ImportHandler) PostImport(c *gin.Context) {
+    req := models.ImportRequest{
+        Root:        c.PostForm("root"),
+        TargetDir:   c.PostForm("targetDir"),
+        Mode:        c.PostForm("mode"),
+        PathMap:     c.PostForm("pathMap"),
+        StrictLinks: c.PostForm("strictLinks") == "true",
+        Sync:        c.PostForm("sync") == "true",
+        Sources:     c.PostFormArray("source"),
+    }
+    data := gin.H{"req": req}
+    if !verifyCSRF(c, h.csrf) {
+        logDenied(c, "import root="+req.Root, fmt.Errorf("CSRF令牌无效"))
+        data["error"] = "页面已过期或请求来源无效，请刷新页面后重试"
+        h.renderImport(c, http.StatusForbidden, data)
+        return
+    }
+    // 页面上未勾选任何文件夹时不导入
+    if len(req.Sources) == 0 {
+        data["error"] = "请选择要导入的文件夹"
+        h.renderImport(c, http.StatusBadRequest, data)
+        return
+    }
+
+    plan, status, err := h.plan(c, &req)
+    if err != nil {
+        data["error"] = err.Error()
+        h.renderImport(c, status, data)
+        return
+    }
+    batch, err := h.imports.Start(plan, req, currentUser(c))
+    if err != nil {
+        data["plan"] = plan
+        data["error"] = err.Error()
+        h.renderImport(c, http.StatusBadRequest, data)
+        return
+    }
+    c.Redirect(http.StatusSeeOther, "/import/"+batch.ID)
+}
+
+// GetImportReport 显示批量导入的汇总报告页面
+func (h *ImportHandler) GetImportReport(c *gin.Context) {
+    report, ok := h.imports.Report(c.Param("id"))
+    if !ok {
+        c.HTML(http.StatusNotFound, "import_report.html", gin.H{
+            "title": "VdSYMLinkTool - 批量导入",
+            "error": "批量导入不存在",
+        })
+        return
+    }
+    c.HTML(http.StatusOK, "import_report.html", gin.H{
+        "title":  "VdSYMLinkTool - 批量导入",
+        "report": report,
+    })
+}
+
+// PlanImport 列出下载目录中的候选文件夹及预计的剧集名、季数和目标目录（JSON）
+func (h *ImportHandler) PlanImport(c *gin.Context) {
+    req := importRequestFromQuery(c)
+    plan, status, err := h.plan(c, &req)
+    if err != nil {
+        if errors.Is(err, services.ErrCancelled) {
+            return
+        }
+        c.JSON(status, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "plan":    plan,
+    })
+}
+
+// StartImport 批量导入选中的候选文件夹（JSON），每个文件夹作为一个任务加入队列
+func (h *ImportHandler) StartImport(c *gin.Context) {
+    if !requireJSON(c) {
+        return
+    }
+    var req models.ImportRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "请求格式错误: " + err.Error(),
+        })
+        return
+    }
+
+    plan, status, err := h.plan(c, &req)
+    if err != nil {
+        if errors.Is(err, services.ErrCancelled) {
+            return
+        }
+        c.JSON(status, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+    batch, err := h.imports.Start(plan, req, currentUser(c))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+    c.JSON(http.StatusAccepted, models.ProcessResponse{
+        Success: true,
+        Message: fmt.Sprintf("已创建批量导入，共 %d 个文件夹", len(batch.Items)),
+        Data:    batch,
+    })
+}
+
+// GetImportStatus 批量导入的汇总报告（JSON）
+func (h *ImportHandler) GetImportStatus(c *gin.Context) {
+    report, ok := h.imports.Report(c.Param("id"))
+    if !ok {
+        c.JSON(http.StatusNotFound, models.ProcessResponse{
+            Success: false,
+            Message: "批量导入不存在",
+        })
+        return
+    }
+    c.JSON(http.StatusOK, models.ProcessResponse{
+        Success: true,
+        Message: "ok",
+        Data:    report,
+    })
+}
+
+// CancelImport 取消批量导入，只有创建批量导入的用户和管理员可以取消
+func (h *ImportHandler) CancelImport(c *gin.Context) {
+    id := c.Param("id")
+    report, ok := h.imports.Report(id)
+    if !ok {
+        c.JSON(http.StatusNotFound, models.ProcessResponse{
+            Success: false,
+            Message: "批量导入不存在",
+        })
+        return
+    }
+    user := currentUser(c)
+    if !h.permissions.IsAdmin(user) && (user == nil || user.Username != report.User) {
+        err := fmt.Errorf("只有创建批量导入的用户和管理员可以取消")
+        logDenied(c, "cancel import id="+id, err)
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    report, _ = h.imports.Cancel(id)
+    c.JSON(http.StatusOK, models.ProcessResponse{
+        Success: true,
+        Message: fmt.Sprintf("已取消批量导入：%d 个文件夹已完成，%d 个文件夹已取消", report.Succeeded, report.Cancelled),
+        Data:    report,
+    })
+}
+
+// ListImports 最近的批量导入报告（JSON）
+func (h *ImportHandler) ListImports(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "imports": h.imports.Reports(50),
+    })
+}
+
+// plan 校验导入参数和用户权限，按用户权限解析下载目录和目标目录后生成导入计划
+func (h *ImportHandler) plan(c *gin.Context, req *models.ImportRequest) (*models.ImportPlan, int, error) {
+    if req.Root == "" || req.TargetDir == "" {
+        return nil, http.StatusBadRequest, fmt.Errorf("请填写下载目录 root 和目标目录 targetDir")
+    }
+    if req.Mode == "" {
+        req.Mode = "link"
+    }
+    if req.Mode != "link" && req.Mode != "move" {
+        return nil, http.StatusBadRequest, fmt.Errorf("批量导入只支持链接和移动模式: %s", req.Mode)
+    }
+    if req.Sync && req.Mode != "link" {
+        return nil, http.StatusBadRequest, fmt.Errorf("同步仅支持链接模式")
+    }
+    if err := h.mapper.Validate(req.PathMap, ""); err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+
+    user := currentUser(c)
+    err := h.permissions.CheckMode(user, req.Mode)
+    var guard *services.PathGuard
+    if err == nil {
+        guard, err = h.permissions.Guard(user, h.guard)
+    }
+    root, targetDir := "", ""
+    if err == nil {
+        root, err = guard.Resolve(req.Root, services.ScopeSource)
+    }
+    if err == nil {
+        targetDir, err = guard.Resolve(req.TargetDir, services.ScopeTarget)
+    }
+    if err != nil {
+        logDenied(c, fmt.Sprintf("import mode=%s root=%s targetDir=%s", req.Mode, req.Root, req.TargetDir), err)
+        return nil, http.StatusForbidden, err
+    }
+
+    plan, err := h.imports.PlanImport(c.Request.Context(), root, targetDir)
+    if err != nil {
+        return nil, http.StatusUnprocessableEntity, err
+    }
+    return plan, http.StatusOK, nil
+}
+
+// renderImport 渲染批量导入页面，附加最近的批量导入
+func (h *ImportHandler) renderImport(c *gin.Context, status int, data gin.H) {
+    data["title"] = "VdSYMLinkTool - 批量导入"
+    data["user"] = currentUser(c)
+    data["csrfToken"] = csrfToken(c, h.csrf)
+    data["pathMaps"] = h.mapper.Names()
+    data["imports"] = h.imports.Reports(20)
+    c.HTML(status, "import.html", data)
+}
+
+// importRequestFromQuery 从查询参数读取导入参数
+func importRequestFromQuery(c *gin.Context) models.ImportRequest {
+    return models.ImportRequest{
+        Root:        c.Query("root"),
+        TargetDir:   c.Query("targetDir"),
+        Mode:        c.Query("mode"),
+        PathMap:     c.Query("pathMap"),
+        StrictLinks: c.Query("strictLinks") == "true",
+        Sync:        c.Query("sync") == "true",
+    }
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+    jobs        *services.JobManager
+    guard       *services.PathGuard
+    permissions *services.PermissionService
+    csrf        *services.CSRFService
+}
+
+func NewJobHandler(jobs *services.JobManager, guard *services.PathGuard, permissions *services.PermissionService, csrf *services.CSRFService) *JobHandler {
+    return &JobHandler{
+        jobs:        jobs,
+        guard:       guard,
+        permissions: permissions,
+        csrf:        csrf,
+    }
+}
+
+// GetJob 显示任务结果页面
+func (h *JobHandler) GetJob(c *gin.Context) {
+    job, ok := h.find(c, c.Param("id"))
+    if !ok {
+        h.renderJob(c, http.StatusNotFound, gin.H{
+            "error": "任务不存在或已过期",
+        })
+        return
+    }
+
+    h.renderJob(c, http.StatusOK, gin.H{
+        "job":      job,
+        "rerunURL": rerunURL(job.Request),
+        "canUndo":  canUndo(job),
+    })
+}
+
+// UndoJob 撤销任务，结果保存为新任务
+func (h *JobHandler) UndoJob(c *gin.Context) {
+    id := c.Param("id")
+    if !verifyCSRF(c, h.csrf) {
+        logDenied(c, "job="+id, fmt.Errorf("CSRF令牌无效"))
+        h.renderJob(c, http.StatusForbidden, gin.H{
+            "error": "页面已过期或请求来源无效，请刷新页面后重试",
+        })
+        return
+    }
+
+    job, ok := h.find(c, id)
+    if !ok {
+        h.renderJob(c, http.StatusNotFound, gin.H{
+            "error": "任务不存在或已过期",
+        })
+        return
+    }
+
+    // 撤销需要原任务模式的权限以及对原路径的访问权限
+    user := currentUser(c)
+    if err := h.checkAccess(user, job.Request); err != nil {
+        logDenied(c, "undo job="+id, err)
+        h.renderJob(c, http.StatusForbidden, gin.H{
+            "job":      job,
+            "rerunURL": rerunURL(job.Request),
+            "error":    err.Error(),
+        })
+        return
+    }
+
+    undoJob, err := h.jobs.Undo(c.Request.Context(), job, user)
+    var busy *services.BusyError
+    if errors.As(err, &busy) {
+        h.renderJob(c, http.StatusConflict, gin.H{
+            "job":      job,
+            "rerunURL": rerunURL(job.Request),
+            "canUndo":  canUndo(job),
+            "error":    err.Error(),
+        })
+        return
+    }
+    if err != nil {
+        c.Redirect(http.StatusSeeOther, "/jobs/"+id)
+        return
+    }
+
+    c.Redirect(http.StatusSeeOther, "/jobs/"+undoJob.ID)
+}
+
+// ListJobs 列出任务（JSON），支持 status 和 limit 参数
+func (h *JobHandler) ListJobs(c *gin.Context) {
+    limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "jobs":    h.jobs.List(c.Query("status"), limit, h.visibleTo(currentUser(c))),
+    })
+}
+
+// GetHistory 显示任务历史页面，支持按剧集名、模式、状态和文件筛选
+func (h *JobHandler) GetHistory(c *gin.Context) {
+    filter := h.historyFilter(c)
+    page := h.jobs.History(filter)
+
+    // 分页链接保留筛选条件
+    query := c.Request.URL.Query()
+    pageURL := func(n int) string {
+        query.Set("page", strconv.Itoa(n))
+        return "/history?" + query.Encode()
+    }
+    data := gin.H{
+        "title":   "VdSYMLinkTool - 任务历史",
+        "user":    currentUser(c),
+        "filter":  filter,
+        "history": page,
+        "modes":   []string{"link", "move", "rename", "migrate"},
+        "statuses": []string{
+            models.JobSucceeded, models.JobFailed, models.JobCancelled,
+            models.JobRunning, models.JobWaiting, models.JobQueued,
+        },
+    }
+    if page.Page > 1 {
+        data["prevURL"] = pageURL(page.Page - 1)
+    }
+    if page.Page < page.Pages {
+        data["nextURL"] = pageURL(page.Page + 1)
+    }
+    c.HTML(http.StatusOK, "history.html", data)
+}
+
+// ListHistory 分页查询任务历史（JSON）
+func (h *JobHandler) ListHistory(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "history": h.jobs.History(h.historyFilter(c)),
+    })
+}
+
+// historyFilter 从查询参数读取任务历史的筛选条件，只列出当前用户可以查看的任务
+func (h *JobHandler) historyFilter(c *gin.Context) services.HistoryFilter {
+    page, _ := strconv.Atoi(c.Query("page"))
+    pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+    return services.HistoryFilter{
+        Series:   c.Query("series"),
+        Mode:     c.Query("mode"),
+        Status:   c.Query("status"),
+        User:     c.Query("user"),
+        File:     c.Query("file"),
+        Page:     page,
+        PageSize: pageSize,
+        Visible:  h.visibleTo(currentUser(c)),
+    }
+}
+
+// GetJobStatus 获取任务状态、进度和结果（JSON）
+func (h *JobHandler) GetJobStatus(c *gin.Context) {
+    job, ok := h.find(c, c.Param("id"))
+    if !ok {
+        c.JSON(http.StatusNotFound, models.ProcessResponse{
+            Success: false,
+            Message: "任务不存在或已过期",
+        })
+        return
+    }
+    c.JSON(http.StatusOK, models.ProcessResponse{
+        Success: true,
+        Message: job.Status,
+        Data:    job,
+        JobID:   job.ID,
+    })
+}
+
+// StreamJobEvents 通过SSE实时推送任务的日志、文件操作和进度
+func (h *JobHandler) StreamJobEvents(c *gin.Context) {
+    id := c.Param("id")
+
+    // 先订阅再读取任务状态，避免错过订阅前结束的任务
+    events, replay, cancel := h.jobs.Subscribe(id)
+    defer cancel()
+
+    job, ok := h.find(c, id)
+    if !ok {
+        c.JSON(http.StatusNotFound, models.ProcessResponse{
+            Success: false,
+            Message: "任务不存在或已过期",
+        })
+        return
+    }
+
+    c.Header("Cache-Control", "no-cache")
+    c.Header("X-Accel-Buffering", "no")
+    c.SSEvent(models.EventStatus, models.JobEvent{Type: models.EventStatus, Job: job})
+    for _, event := range replay {
+        c.SSEvent(event.Type, event)
+    }
+    c.Writer.Flush()
+    if job.Finished() {
+        return
+    }
+
+    keepalive := time.NewTicker(20 * time.Second)
+    defer keepalive.Stop()
+
+    c.Stream(func(w io.Writer) bool {
+        select {
+        case event, ok := <-events:
+            if !ok {
+                return false
+            }
+            c.SSEvent(event.Type, event)
+            return event.Type != models.EventStatus || !event.Job.Finished()
+        case <-keepalive.C:
+            c.SSEvent("ping", "")
+            return true
+        case <-c.Request.Context().Done():
+            return false
+        }
+    })
+}
+
+// CancelJob 取消任务，需要任务模式的权限以及对任务路径的访问权限
+func (h *JobHandler) CancelJob(c *gin.Context) {
+    id := c.Param("id")
+    job, ok := h.find(c, id)
+    if !ok {
+        c.JSON(http.StatusNotFound, models.ProcessResponse{
+            Success: false,
+            Message: "任务不存在或已过期",
+        })
+        return
+    }
+    if err := h.checkAccess(currentUser(c), job.Request); err != nil {
+        logDenied(c, "cancel job="+id, err)
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    job, err := h.jobs.Cancel(id)
+    if err != nil {
+        status := http.StatusConflict
+        if job == nil {
+            status = http.StatusNotFound
+        }
+        c.JSON(status, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+            Data:    job,
+        })
+        return
+    }
+
+    message := "任务已取消"
+    if job.Status == models.JobRunning {
+        message = "已请求取消，任务将在当前文件处理完成后停止"
+    } else if job.Result != nil {
+        message = fmt.Sprintf("任务已取消：完成 %d 个文件，%d 个文件未处理", job.Progress.Completed, job.Progress.Cancelled)
+    }
+    c.JSON(http.StatusOK, models.ProcessResponse{
+        Success: true,
+        Message: message,
+        Data:    job,
+        JobID:   job.ID,
+    })
+}
+
+// find 查找当前用户可以查看的任务，无权查看的任务视为不存在
+func (h *JobHandler) find(c *gin.Context, id string) (*models.Job, bool) {
+    job, ok := h.jobs.Get(id)
+    if !ok {
+        return nil, false
+    }
+    if visible := h.visibleTo(currentUser(c)); visible != nil && !visible(job) {
+        logDenied(c, "job="+id, fmt.Errorf("任务路径不在用户可访问的范围内"))
+        return nil, false
+    }
+    return job, true
+}
+
+// visibleTo 用户可以查看的任务：管理员可以查看所有任务，其他用户只能查看路径都在其可访问范围内的任务
+// 返回 nil 表示不限制
+func (h *JobHandler) visibleTo(user *services.User) func(job *models.Job) bool {
+    if h.permissions.IsAdmin(user) {
+        return nil
+    }
+    guard, err := h.permissions.Guard(user, h.guard)
+    if err != nil {
+        return func(*models.Job) bool { return false }
+    }
+    return func(job *models.Job) bool {
+        return checkPaths(guard, job.Request) == nil
+    }
+}
+
+// checkAccess 检查用户对任务模式和路径的权限
+func (h *JobHandler) checkAccess(user *services.User, req models.ProcessRequest) error {
+    if err := h.permissions.CheckMode(user, req.Mode); err != nil {
+        return err
+    }
+    guard, err := h.permissions.Guard(user, h.guard)
+    if err != nil {
+        return err
+    }
+    return checkPaths(guard, req)
+}
+
+// checkPaths 检查任务的源目录和目标目录是否在路径范围内
+func checkPaths(guard *services.PathGuard, req models.ProcessRequest) error {
+    // 迁移任务只涉及目标目录
+    if req.Mode != "migrate" {
+        if _, err := guard.Resolve(req.SourceDir, services.ScopeSource); err != nil {
+            return err
+        }
+    }
+    if req.Mode != "rename" {
+        if _, err := guard.Resolve(req.TargetDir, services.ScopeTarget); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// renderJob 渲染任务页面
+func (h *JobHandler) renderJob(c *gin.Context, status int, data gin.H) {
+    data["title"] = "VdSYMLinkTool - 任务"
+    data["user"] = currentUser(c)
+    data["csrfToken"] = csrfToken(c, h.csrf)
+    c.HTML(status, "job.html", data)
+}
+
+// canUndo 任务是否可以撤销
+func canUndo(job *models.Job) bool {
+    return job.Finished() && job.Result != nil && job.Result.Processed+job.Result.Removed > 0 && job.UndoOf == "" && job.UndoneBy == ""
+}
+
+// rerunURL 使用相同参数预填首页表单的地址，迁移任务不能从首页重新运行
+func rerunURL(req models.ProcessRequest) string {
+    if req.Mode == "migrate" {
+        return ""
+    }
+    query := url.Values{}
+    query.Set("sourceDir", req.SourceDir)
+    query.Set("targetDir", req.TargetDir)
+    query.Set("mode", req.Mode)
+    query.Set("redirectPath", req.RedirectPath)
+    query.Set("pathMap", req.PathMap)
+    if req.StrictLinks {
+        query.Set("strictLinks", "true")
+    }
+    if req.Sync {
+        query.Set("sync", "true")
+    }
+    return "/?" + query.Encode()
+}
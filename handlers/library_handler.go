@@ -0,0 +1,289 @@
+package handlers
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+type LibraryHandler struct {
+    library     *services.LibraryService
+    jobs        *services.JobManager
+    guard       *services.PathGuard
+    permissions *services.PermissionService
+}
+
+func NewLibraryHandler(library *services.LibraryService, jobs *services.JobManager, guard *services.PathGuard, permissions *services.PermissionService) *LibraryHandler {
+    return &LibraryHandler{
+        library:     library,
+        jobs:        jobs,
+        guard:       guard,
+        permissions: permissions,
+    }
+}
+
+// ScanLibrary 扫描目标目录中的断开链接，format=csv 或 txt 时导出断链列表
+func (h *LibraryHandler) ScanLibrary(c *gin.Context) {
+    _, root, searchDirs, ok := h.resolve(c, c.Query("root"), c.QueryArray("searchDir"))
+    if !ok {
+        return
+    }
+
+    scan, err := h.library.Scan(c.Request.Context(), root, searchDirs)
+    if err != nil {
+        h.respondScanError(c, err)
+        return
+    }
+
+    switch c.Query("format") {
+    case "csv":
+        c.Header("Content-Disposition", `attachment; filename="broken-links.csv"`)
+        c.Header("Content-Type", "text/csv; charset=utf-8")
+        writer := csv.NewWriter(c.Writer)
+        writer.Write([]string{"series", "season", "path", "linkTarget", "candidates"})
+        for _, group := range scan.Groups {
+            for _, link := range group.Links {
+                var candidates []string
+                for _, candidate := range link.Candidates {
+                    candidates = append(candidates, candidate.Path)
+                }
+                writer.Write([]string{group.Series, group.Season, link.Path, link.LinkTarget, strings.Join(candidates, "|")})
+            }
+        }
+        writer.Flush()
+    case "txt":
+        c.Header("Content-Disposition", `attachment; filename="broken-links.txt"`)
+        var lines strings.Builder
+        for _, group := range scan.Groups {
+            for _, link := range group.Links {
+                lines.WriteString(link.Path + "\n")
+            }
+        }
+        c.String(http.StatusOK, lines.String())
+    default:
+        c.JSON(http.StatusOK, gin.H{
+            "success": true,
+            "scan":    scan,
+        })
+    }
+}
+
+// RepairLibrary 删除断开的链接或将其重新指向移动后的源文件
+func (h *LibraryHandler) RepairLibrary(c *gin.Context) {
+    if !requireJSON(c) {
+        return
+    }
+    var req models.RepairRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "请求格式错误: " + err.Error(),
+        })
+        return
+    }
+
+    // 重新指向链接需要链接模式权限，删除链接需要移动模式权限，
+    // 修复不是本工具创建的链接只允许管理员
+    user := currentUser(c)
+    mode := "link"
+    if req.Action == models.RepairDelete {
+        mode = "move"
+    }
+    err := h.permissions.CheckMode(user, mode)
+    if err == nil && req.Force && !h.permissions.IsAdmin(user) {
+        err = fmt.Errorf("只有管理员可以修复不是本工具创建的链接")
+    }
+    if err != nil {
+        logDenied(c, "repair library root="+req.Root, err)
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+    guard, root, searchDirs, ok := h.resolve(c, req.Root, req.SearchDirs)
+    if !ok {
+        return
+    }
+
+    // 修复期间锁定媒体库目录，避免与处理或迁移该目录的任务同时修改
+    var results []models.RepairResult
+    err = h.jobs.Exclusive("repair", []string{root}, func() error {
+        var err error
+        results, err = h.library.Repair(c.Request.Context(), req, root, searchDirs, guard)
+        return err
+    })
+    if data := busyData(err); data != nil {
+        c.JSON(http.StatusConflict, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+            Data:    data,
+        })
+        return
+    }
+    if err != nil && !errors.Is(err, services.ErrCancelled) {
+        h.respondScanError(c, err)
+        return
+    }
+
+    repaired := 0
+    for _, result := range results {
+        if result.Success {
+            repaired++
+        }
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "success": repaired == len(results),
+        "message": fmt.Sprintf("已修复 %d 个，失败 %d 个", repaired, len(results)-repaired),
+        "results": results,
+    })
+}
+
+// ListReferences 查找媒体库中引用源文件或目录的符号链接、硬链接和 .strm 文件
+func (h *LibraryHandler) ListReferences(c *gin.Context) {
+    path, roots, ok := h.resolveReferences(c, c.Query("path"), c.QueryArray("root"))
+    if !ok {
+        return
+    }
+
+    report, err := h.library.References(c.Request.Context(), path, roots)
+    if err != nil {
+        h.respondScanError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "report":  report,
+    })
+}
+
+// ReferenceCounts 统计源目录的各子目录在媒体库中被引用的次数，用于目录浏览中的标记
+func (h *LibraryHandler) ReferenceCounts(c *gin.Context) {
+    dir, roots, ok := h.resolveReferences(c, c.Query("dir"), c.QueryArray("root"))
+    if !ok {
+        return
+    }
+
+    counts, err := h.library.ReferenceCounts(c.Request.Context(), dir, roots)
+    if err != nil {
+        h.respondScanError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "dir":     dir,
+        "counts":  counts,
+    })
+}
+
+// resolveReferences 按用户权限解析源路径和要查找的媒体库目录，未指定媒体库目录时使用目标根目录
+func (h *LibraryHandler) resolveReferences(c *gin.Context, path string, dirs []string) (string, []string, bool) {
+    if path == "" {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "请指定源文件或目录",
+        })
+        return "", nil, false
+    }
+
+    user := currentUser(c)
+    guard, err := h.permissions.Guard(user, h.guard)
+    resolvedPath := ""
+    if err == nil {
+        resolvedPath, err = guard.Resolve(path, services.ScopeSource)
+    }
+    if err != nil {
+        logDenied(c, "references path="+path, err)
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return "", nil, false
+    }
+
+    if len(dirs) == 0 {
+        dirs = guard.Roots(services.ScopeTarget)
+    }
+    if len(dirs) == 0 {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "未配置目标根目录，请通过 root 指定要查找的媒体库目录",
+        })
+        return "", nil, false
+    }
+    var roots []string
+    for _, dir := range dirs {
+        resolved, err := guard.Resolve(dir, services.ScopeTarget)
+        if err != nil {
+            logDenied(c, "references root="+dir, err)
+            c.JSON(http.StatusForbidden, models.ProcessResponse{
+                Success: false,
+                Message: err.Error(),
+            })
+            return "", nil, false
+        }
+        roots = append(roots, resolved)
+    }
+    return resolvedPath, roots, true
+}
+
+// resolve 按用户权限解析媒体库目录和查找源文件的目录，未指定查找目录时使用源根目录
+func (h *LibraryHandler) resolve(c *gin.Context, root string, dirs []string) (*services.PathGuard, string, []string, bool) {
+    if root == "" {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "请指定要扫描的目标目录 root",
+        })
+        return nil, "", nil, false
+    }
+
+    user := currentUser(c)
+    guard, err := h.permissions.Guard(user, h.guard)
+    resolvedRoot := ""
+    if err == nil {
+        resolvedRoot, err = guard.Resolve(root, services.ScopeTarget)
+    }
+    if err != nil {
+        logDenied(c, "library root="+root, err)
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return nil, "", nil, false
+    }
+
+    if len(dirs) == 0 {
+        dirs = guard.Roots(services.ScopeSource)
+    }
+    var searchDirs []string
+    for _, dir := range dirs {
+        resolved, err := guard.Resolve(dir, services.ScopeSource)
+        if err != nil {
+            logDenied(c, "library searchDir="+dir, err)
+            c.JSON(http.StatusForbidden, models.ProcessResponse{
+                Success: false,
+                Message: err.Error(),
+            })
+            return nil, "", nil, false
+        }
+        searchDirs = append(searchDirs, resolved)
+    }
+    return guard, resolvedRoot, searchDirs, true
+}
+
+// respondScanError 扫描失败时响应，客户端断开时不再响应
+func (h *LibraryHandler) respondScanError(c *gin.Context, err error) {
+    if errors.Is(err, services.ErrCancelled) {
+        return
+    }
+    c.JSON(http.StatusUnprocessableEntity, models.ProcessResponse{
+        Success: false,
+        Message: err.Error(),
+    })
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+type MigrationHandler struct {
+    service     *services.SymlinkService
+    jobs        *services.JobManager
+    guard       *services.PathGuard
+    permissions *services.PermissionService
+}
+
+func NewMigrationHandler(service *services.SymlinkService, jobs *services.JobManager, guard *services.PathGuard, permissions *services.PermissionService) *MigrationHandler {
+    return &MigrationHandler{
+        service:     service,
+        jobs:        jobs,
+        guard:       guard,
+        permissions: permissions,
+    }
+}
+
+// PlanMigration 显示将媒体库迁移到命名模板的计划，不修改任何文件
+func (h *MigrationHandler) PlanMigration(c *gin.Context) {
+    root, naming, ok := h.resolve(c, c.Query("root"), c.Query("naming"))
+    if !ok {
+        return
+    }
+
+    plan, err := h.service.PlanMigration(c.Request.Context(), root, naming)
+    if err != nil {
+        if errors.Is(err, services.ErrCancelled) {
+            return
+        }
+        c.JSON(http.StatusUnprocessableEntity, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "plan":    plan,
+    })
+}
+
+// Migrate 按命名模板迁移媒体库，作为任务执行并记录每次重命名，可撤销
+func (h *MigrationHandler) Migrate(c *gin.Context) {
+    if !requireJSON(c) {
+        return
+    }
+    var req models.MigrationRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "请求格式错误: " + err.Error(),
+        })
+        return
+    }
+    root, naming, ok := h.resolve(c, req.Root, req.Naming)
+    if !ok {
+        return
+    }
+
+    user := currentUser(c)
+    jobReq := models.ProcessRequest{TargetDir: root, Mode: "migrate", Naming: naming.Name, Async: req.Async}
+    opts := services.ProcessOptions{TargetDir: root, Mode: "migrate", Naming: naming.Name}
+
+    if req.Async {
+        job, err := h.jobs.Enqueue(jobReq, user, opts)
+        if err != nil {
+            c.JSON(http.StatusServiceUnavailable, models.ProcessResponse{
+                Success: false,
+                Message: err.Error(),
+            })
+            return
+        }
+        c.JSON(http.StatusAccepted, models.ProcessResponse{
+            Success: true,
+            Message: "任务已加入队列",
+            Data:    job,
+            JobID:   job.ID,
+        })
+        return
+    }
+
+    job, err := h.jobs.Run(c.Request.Context(), jobReq, user, opts)
+    if err != nil {
+        // 目录正被其他任务使用
+        c.JSON(http.StatusConflict, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+            Data:    busyData(err),
+        })
+        return
+    }
+    switch job.Status {
+    case models.JobFailed:
+        c.JSON(http.StatusInternalServerError, models.ProcessResponse{
+            Success: false,
+            Message: "迁移失败: " + job.Error,
+            JobID:   job.ID,
+        })
+    case models.JobCancelled:
+        c.JSON(http.StatusOK, models.ProcessResponse{
+            Success: false,
+            Message: "任务已取消",
+            Data:    job.Result,
+            JobID:   job.ID,
+        })
+    default:
+        c.JSON(http.StatusOK, models.ProcessResponse{
+            Success: true,
+            Message: "迁移完成",
+            Data:    job.Result,
+            JobID:   job.ID,
+        })
+    }
+}
+
+// resolve 检查迁移权限，按用户权限解析媒体库目录并查找命名模板
+func (h *MigrationHandler) resolve(c *gin.Context, root, name string) (string, services.NamingTemplate, bool) {
+    if root == "" {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: "请指定要迁移的媒体库目录 root",
+        })
+        return "", services.NamingTemplate{}, false
+    }
+    naming, err := services.LookupNaming(name)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return "", services.NamingTemplate{}, false
+    }
+
+    user := currentUser(c)
+    err = h.permissions.CheckMode(user, "migrate")
+    var guard *services.PathGuard
+    if err == nil {
+        guard, err = h.permissions.Guard(user, h.guard)
+    }
+    resolvedRoot := ""
+    if err == nil {
+        resolvedRoot, err = guard.Resolve(root, services.ScopeTarget)
+    }
+    if err != nil {
+        logDenied(c, "migrate root="+root, err)
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return "", services.NamingTemplate{}, false
+    }
+    return resolvedRoot, naming, true
+}
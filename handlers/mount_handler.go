@@ -0,0 +1,86 @@
+package handlers
+
+import (
+    "net/http"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+type MountHandler struct {
+    mounts      *services.MountService
+    guard       *services.PathGuard
+    permissions *services.PermissionService
+}
+
+func NewMountHandler(mounts *services.MountService, guard *services.PathGuard, permissions *services.PermissionService) *MountHandler {
+    return &MountHandler{
+        mounts:      mounts,
+        guard:       guard,
+        permissions: permissions,
+    }
+}
+
+// ListMounts 列出用户可访问的源目录和目标目录所在的挂载点，指定 path 时同时返回该路径的宿主机路径和建议的重定向路径
+func (h *MountHandler) ListMounts(c *gin.Context) {
+    guard, err := h.permissions.Guard(currentUser(c), h.guard)
+    if err != nil {
+        logDenied(c, "mounts", err)
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    // 路径需位于可访问的源目录或目标目录下
+    path := c.Query("path")
+    if path != "" {
+        resolved, err := guard.Resolve(path, services.ScopeSource)
+        if err != nil {
+            resolved, err = guard.Resolve(path, services.ScopeTarget)
+        }
+        if err != nil {
+            logDenied(c, "mounts path="+path, err)
+            c.JSON(http.StatusBadRequest, models.ProcessResponse{
+                Success: false,
+                Message: err.Error(),
+            })
+            return
+        }
+        path = resolved
+    }
+
+    all, err := h.mounts.Mounts()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+    mounts := []models.Mount{}
+    for _, mount := range all {
+        if guard.Overlaps(mount.MountPoint) {
+            mounts = append(mounts, mount)
+        }
+    }
+
+    response := gin.H{
+        "success": true,
+        "mounts":  mounts,
+    }
+    if path != "" {
+        suggestion, err := h.mounts.Suggest(path)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, models.ProcessResponse{
+                Success: false,
+                Message: err.Error(),
+            })
+            return
+        }
+        response["suggestion"] = suggestion
+    }
+    c.JSON(http.StatusOK, response)
+}
@@ -1,8 +1,9 @@
 package handlers
 
 import (
+    "errors"
+    "fmt"
     "net/http"
-    "net/url"
     "os"
     "path/filepath"
     "strings"
@@ -13,12 +14,20 @@ import (
 )
 
 type SymlinkHandler struct {
-    service *services.SymlinkService
+    jobs        *services.JobManager
+    guard       *services.PathGuard
+    mapper      *services.PathMapper
+    permissions *services.PermissionService
+    csrf        *services.CSRFService
 }
 
-func NewSymlinkHandler() *SymlinkHandler {
+func NewSymlinkHandler(jobs *services.JobManager, guard *services.PathGuard, mapper *services.PathMapper, permissions *services.PermissionService, csrf *services.CSRFService) *SymlinkHandler {
     return &SymlinkHandler{
-        service: services.NewSymlinkService(),
+        jobs:        jobs,
+        guard:       guard,
+        mapper:      mapper,
+        permissions: permissions,
+        csrf:        csrf,
     }
 }
 
@@ -26,15 +35,27 @@ func NewSymlinkHandler() *SymlinkHandler {
 func (h *SymlinkHandler) ProcessFiles(c *gin.Context) {
     var req models.ProcessRequest
 
-    // 根据Content-Type决定如何绑定数据
-    contentType := c.GetHeader("Content-Type")
-    if contentType == "application/x-www-form-urlencoded" {
-        // 表单提交
+    // 根据Content-Type决定如何绑定数据，其他类型一律拒绝
+    contentType := mediaType(c)
+    isForm := contentType == contentTypeForm
+    switch contentType {
+    case contentTypeForm:
+        // 表单提交，必须携带有效的CSRF令牌
         req.SourceDir = c.PostForm("sourceDir")
         req.TargetDir = c.PostForm("targetDir")
         req.Mode = c.PostForm("mode")
         req.RedirectPath = c.PostForm("redirectPath")
-    } else {
+        req.PathMap = c.PostForm("pathMap")
+        req.StrictLinks = c.PostForm("strictLinks") == "true"
+        req.Sync = c.PostForm("sync") == "true"
+        req.Async = c.PostForm("async") == "true"
+
+        if !verifyCSRF(c, h.csrf) {
+            logDenied(c, "", fmt.Errorf("CSRF令牌无效"))
+            h.respondError(c, isForm, http.StatusForbidden, req, "页面已过期或请求来源无效，请刷新页面后重试")
+            return
+        }
+    case contentTypeJSON:
         // JSON提交
         if err := c.ShouldBindJSON(&req); err != nil {
             c.JSON(http.StatusBadRequest, models.ProcessResponse{
@@ -43,142 +64,276 @@ func (h *SymlinkHandler) ProcessFiles(c *gin.Context) {
             })
             return
         }
+    default:
+        c.JSON(http.StatusUnsupportedMediaType, models.ProcessResponse{
+            Success: false,
+            Message: "不支持的Content-Type，请使用 application/json 或 application/x-www-form-urlencoded",
+        })
+        return
     }
 
-    // 验证必填字段
-    if req.SourceDir == "" {
-        if contentType == "application/x-www-form-urlencoded" {
-            c.HTML(http.StatusOK, "index.html", gin.H{
-                "title": "VdSYMLinkTool",
-                "error": "视频目录路径不能为空",
-                "sourceDir": req.SourceDir,
-                "targetDir": req.TargetDir,
-                "mode": req.Mode,
-                "redirectPath": req.RedirectPath,
-            })
+    opts, status, err := h.prepare(c, &req)
+    if err != nil {
+        h.respondError(c, isForm, status, req, err.Error())
+        return
+    }
+    user := currentUser(c)
+
+    // 异步提交：加入任务队列后立即返回任务ID
+    if req.Async || c.Query("async") == "true" {
+        job, err := h.jobs.Enqueue(req, user, opts)
+        if err != nil {
+            h.respondError(c, isForm, http.StatusServiceUnavailable, req, err.Error())
+            return
+        }
+        if isForm {
+            c.Redirect(http.StatusSeeOther, "/jobs/"+job.ID)
         } else {
-            c.JSON(http.StatusBadRequest, models.ProcessResponse{
-                Success: false,
-                Message: "视频目录路径不能为空",
+            c.JSON(http.StatusAccepted, models.ProcessResponse{
+                Success: true,
+                Message: "任务已加入队列",
+                Data:    job,
+                JobID:   job.ID,
             })
         }
         return
     }
 
-    if req.Mode == "" {
-        req.Mode = "rename"
-    }
-
-    if req.Mode == "rename" {
-        req.TargetDir = ""
-        req.RedirectPath = ""
-    } else if req.TargetDir == "" {
-        if contentType == "application/x-www-form-urlencoded" {
-            c.HTML(http.StatusOK, "index.html", gin.H{
-                "title": "VdSYMLinkTool",
-                "error": "链接/移动模式需要填写目标目录路径",
-                "sourceDir": req.SourceDir,
-                "targetDir": req.TargetDir,
-                "mode": req.Mode,
-                "redirectPath": req.RedirectPath,
-            })
+    job, err := h.jobs.Run(c.Request.Context(), req, user, opts)
+    if err != nil {
+        // 目录正被其他任务使用
+        if isForm {
+            h.respondError(c, isForm, http.StatusConflict, req, err.Error())
         } else {
-            c.JSON(http.StatusBadRequest, models.ProcessResponse{
+            c.JSON(http.StatusConflict, models.ProcessResponse{
                 Success: false,
-                Message: "链接/移动模式需要填写目标目录路径",
+                Message: err.Error(),
+                Data:    busyData(err),
             })
         }
         return
     }
 
-    result, err := h.service.ProcessFiles(req.SourceDir, req.TargetDir, req.Mode, req.RedirectPath)
-
     // 关键修改：表单提交时使用重定向
-    if contentType == "application/x-www-form-urlencoded" {
-        if err != nil {
+    if isForm {
+        if job.Status == models.JobFailed {
             // 错误情况直接返回页面（用户需要看到错误信息并修正）
-            c.HTML(http.StatusOK, "index.html", gin.H{
-                "title": "VdSYMLinkTool",
-                "error": "处理失败: " + err.Error(),
-                "sourceDir": req.SourceDir,
-                "targetDir": req.TargetDir,
-                "mode": req.Mode,
-                "redirectPath": req.RedirectPath,
-            })
+            h.respondError(c, isForm, http.StatusOK, req, "处理失败: "+job.Error)
         } else {
-            // 成功时重定向，避免重复提交
-            c.Redirect(http.StatusSeeOther, "/?success=true&result="+url.QueryEscape(result)+
-                "&sourceDir="+url.QueryEscape(req.SourceDir)+
-                "&targetDir="+url.QueryEscape(req.TargetDir)+
-                "&mode="+url.QueryEscape(req.Mode)+
-                "&redirectPath="+url.QueryEscape(req.RedirectPath))
+            // 成功时重定向到任务页面，避免重复提交
+            c.Redirect(http.StatusSeeOther, "/jobs/"+job.ID)
         }
     } else {
-        // JSON响应保持不变
-        if err != nil {
+        // JSON响应：成功时 data 为处理日志，失败返回500，取消时 data 为已完成和未处理的文件操作
+        if job.Status == models.JobFailed {
             c.JSON(http.StatusInternalServerError, models.ProcessResponse{
                 Success: false,
-                Message: "处理失败: " + err.Error(),
+                Message: "处理失败: " + job.Error,
+                JobID:   job.ID,
+            })
+        } else if job.Status == models.JobCancelled {
+            c.JSON(http.StatusOK, models.ProcessResponse{
+                Success: false,
+                Message: "任务已取消",
+                Data:    job.Result,
+                JobID:   job.ID,
             })
         } else {
             c.JSON(http.StatusOK, models.ProcessResponse{
                 Success: true,
                 Message: "处理完成",
-                Data:    result,
+                Data:    job.Result.Log,
+                JobID:   job.ID,
             })
         }
     }
 }
 
-// GetIndex 显示首页（支持查询参数）
+// prepare 校验处理请求，检查用户权限并解析路径，返回处理选项，失败时返回对应的HTTP状态码
+func (h *SymlinkHandler) prepare(c *gin.Context, req *models.ProcessRequest) (services.ProcessOptions, int, error) {
+    // 验证必填字段
+    if req.SourceDir == "" {
+        return services.ProcessOptions{}, http.StatusBadRequest, fmt.Errorf("视频目录路径不能为空")
+    }
+
+    if req.Mode == "" {
+        req.Mode = "rename"
+    }
+
+    // 迁移通过 /api/library/migration 执行
+    if req.Mode != "link" && req.Mode != "move" && req.Mode != "rename" {
+        return services.ProcessOptions{}, http.StatusBadRequest, fmt.Errorf("不支持的模式: %s", req.Mode)
+    }
+    if req.Mode == "rename" {
+        req.TargetDir = ""
+        req.RedirectPath = ""
+        req.PathMap = ""
+    } else if req.TargetDir == "" {
+        return services.ProcessOptions{}, http.StatusBadRequest, fmt.Errorf("链接/移动模式需要填写目标目录路径")
+    }
+    if req.Sync && req.Mode != "link" {
+        return services.ProcessOptions{}, http.StatusBadRequest, fmt.Errorf("同步仅支持链接模式")
+    }
+    if err := h.mapper.Validate(req.PathMap, req.RedirectPath); err != nil {
+        return services.ProcessOptions{}, http.StatusBadRequest, err
+    }
+    if _, err := services.LookupNaming(req.Naming); err != nil {
+        return services.ProcessOptions{}, http.StatusBadRequest, err
+    }
+
+    // 检查用户权限以及路径是否位于允许的根目录下
+    user := currentUser(c)
+    err := h.permissions.CheckMode(user, req.Mode)
+    var guard *services.PathGuard
+    if err == nil {
+        guard, err = h.permissions.Guard(user, h.guard)
+    }
+    sourceDir, targetDir := "", ""
+    if err == nil {
+        sourceDir, err = guard.Resolve(req.SourceDir, services.ScopeSource)
+    }
+    if err == nil && req.Mode != "rename" {
+        targetDir, err = guard.Resolve(req.TargetDir, services.ScopeTarget)
+    }
+    if err != nil {
+        logDenied(c, fmt.Sprintf("mode=%s sourceDir=%s targetDir=%s", req.Mode, req.SourceDir, req.TargetDir), err)
+        return services.ProcessOptions{}, http.StatusForbidden, err
+    }
+
+    return services.ProcessOptions{
+        SourceDir:    sourceDir,
+        TargetDir:    targetDir,
+        Mode:         req.Mode,
+        RedirectPath: req.RedirectPath,
+        PathMap:      req.PathMap,
+        StrictLinks:  req.StrictLinks,
+        Sync:         req.Sync,
+        Naming:       req.Naming,
+    }, http.StatusOK, nil
+}
+
+// GetIndex 显示首页（支持通过查询参数预填表单）
 func (h *SymlinkHandler) GetIndex(c *gin.Context) {
-    result := c.Query("result")
-    success := c.Query("success") == "true"
-    sourceDir := c.Query("sourceDir")
-    targetDir := c.Query("targetDir")
-    mode := c.Query("mode")
-    redirectPath := c.Query("redirectPath")
-
-    c.HTML(http.StatusOK, "index.html", gin.H{
-        "title":        "VdSYMLinkTool",
-        "result":       result,
-        "success":      success,
-        "sourceDir":    sourceDir,
-        "targetDir":    targetDir,
-        "mode":         mode,
-        "redirectPath": redirectPath,
+    h.renderIndex(c, http.StatusOK, gin.H{
+        "sourceDir":    c.Query("sourceDir"),
+        "targetDir":    c.Query("targetDir"),
+        "mode":         c.Query("mode"),
+        "redirectPath": c.Query("redirectPath"),
+        "pathMap":      c.Query("pathMap"),
+        "strictLinks":  c.Query("strictLinks") == "true",
+        "sync":         c.Query("sync") == "true",
     })
 }
 
-func getParentPath(path string) string {
-    if path == "" || path == "/" {
-        return "" // 根目录没有父目录
-    }
+// renderIndex 渲染首页，附加当前用户和CSRF令牌
+func (h *SymlinkHandler) renderIndex(c *gin.Context, status int, data gin.H) {
+    data["title"] = "VdSYMLinkTool"
+    data["user"] = currentUser(c)
+    data["csrfToken"] = csrfToken(c, h.csrf)
+    data["pathMaps"] = h.mapper.Names()
+    c.HTML(status, "index.html", data)
+}
 
-    parent := filepath.Dir(path)
-    if parent == path {
-        return "" // 避免无限循环
+// respondError 表单提交时返回带错误信息的页面，JSON提交时返回错误JSON
+func (h *SymlinkHandler) respondError(c *gin.Context, isForm bool, status int, req models.ProcessRequest, message string) {
+    if isForm {
+        h.renderIndex(c, status, gin.H{
+            "error":        message,
+            "sourceDir":    req.SourceDir,
+            "targetDir":    req.TargetDir,
+            "mode":         req.Mode,
+            "redirectPath": req.RedirectPath,
+            "pathMap":      req.PathMap,
+            "strictLinks":  req.StrictLinks,
+            "sync":         req.Sync,
+        })
+    } else {
+        c.JSON(status, models.ProcessResponse{
+            Success: false,
+            Message: message,
+        })
     }
-    return parent
 }
 
 // ListDirectories 列出目录
 func (h *SymlinkHandler) ListDirectories(c *gin.Context) {
     path := c.Query("path")
+    scope := c.Query("scope")
+
+    var directories []map[string]string
+
+    guard, err := h.permissions.Guard(currentUser(c), h.guard)
+    if err != nil {
+        logDenied(c, "path="+path, err)
+        c.JSON(http.StatusForbidden, gin.H{
+            "success":     false,
+            "message":     err.Error(),
+            "currentPath": path,
+            "directories": directories,
+        })
+        return
+    }
+
     if path == "" {
-        path = "/"
+        roots := guard.Roots(scope)
+        switch {
+        case !guard.Restricted(scope):
+            path = "/"
+        case len(roots) == 0:
+            c.JSON(http.StatusOK, gin.H{
+                "success": false,
+                "message": "没有可访问的目录",
+                "currentPath": "",
+                "directories": directories,
+            })
+            return
+        case len(roots) == 1:
+            path = roots[0]
+        default:
+            // 多个根目录时列出所有根目录供选择
+            for _, root := range roots {
+                directories = append(directories, map[string]string{
+                    "name": root,
+                    "path": root,
+                    "type": "root",
+                })
+            }
+            c.JSON(http.StatusOK, gin.H{
+                "success": true,
+                "currentPath": "",
+                "directories": directories,
+            })
+            return
+        }
     }
 
-    var directories []map[string]string
+    path = filepath.Clean(path)
+    if _, err := guard.Resolve(path, scope); err != nil {
+        logDenied(c, "path="+path, err)
+        c.JSON(http.StatusForbidden, gin.H{
+            "success":     false,
+            "message":     err.Error(),
+            "currentPath": path,
+            "directories": directories,
+        })
+        return
+    }
 
-    // 无论路径是否存在，先添加上级目录项
-    parentPath := getParentPath(path)
+    // 无论路径是否存在，先添加上级目录项（不超出根目录边界）
+    parentPath := guard.Parent(path, scope)
     if parentPath != path && parentPath != "" {
         directories = append(directories, map[string]string{
             "name": "..",
             "path": parentPath,
             "type": "parent",
         })
+    } else if parentPath == "" && len(guard.Roots(scope)) > 1 {
+        // 位于根目录时返回根目录列表
+        directories = append(directories, map[string]string{
+            "name": "..",
+            "path": "",
+            "type": "parent",
+        })
     }
 
     // 尝试读取目录
@@ -223,4 +378,13 @@ func (h *SymlinkHandler) ListDirectories(c *gin.Context) {
         "currentPath": path,
         "directories": directories,
     })
-}
\ No newline at end of file
+}
+
+// busyData 目录正被其他任务使用时响应中的 data：被占用的路径 path 和使用该路径的任务ID jobId
+func busyData(err error) gin.H {
+    var busy *services.BusyError
+    if !errors.As(err, &busy) {
+        return nil
+    }
+    return gin.H{"path": busy.Path, "jobId": busy.JobID}
+}
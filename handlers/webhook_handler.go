@@ -0,0 +1,148 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// 通知记录包含所有任务的路径和通知地址，只有管理员可以查看和重新发送
+const webhookAdminMessage = "只有管理员可以查看和重新发送通知记录"
+
+type WebhookHandler struct {
+    webhooks    *services.WebhookService
+    permissions *services.PermissionService
+    csrf        *services.CSRFService
+}
+
+func NewWebhookHandler(webhooks *services.WebhookService, permissions *services.PermissionService, csrf *services.CSRFService) *WebhookHandler {
+    return &WebhookHandler{
+        webhooks:    webhooks,
+        permissions: permissions,
+        csrf:        csrf,
+    }
+}
+
+// GetDeliveries 显示通知发送记录页面
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+    if !h.permissions.IsAdmin(currentUser(c)) {
+        h.denyPage(c, "webhook deliveries")
+        return
+    }
+    h.renderDeliveries(c, http.StatusOK, gin.H{
+        "message": c.Query("message"),
+    })
+}
+
+// ResendDeliveryForm 从页面重新发送通知
+func (h *WebhookHandler) ResendDeliveryForm(c *gin.Context) {
+    id := c.Param("id")
+    if !h.permissions.IsAdmin(currentUser(c)) {
+        h.denyPage(c, "resend webhook delivery="+id)
+        return
+    }
+    if !verifyCSRF(c, h.csrf) {
+        logDenied(c, "resend webhook delivery="+id, fmt.Errorf("CSRF令牌无效"))
+        h.renderDeliveries(c, http.StatusForbidden, gin.H{
+            "error": "页面已过期或请求来源无效，请刷新页面后重试",
+        })
+        return
+    }
+
+    delivery, err := h.webhooks.Resend(c.Request.Context(), id)
+    if err != nil {
+        h.renderDeliveries(c, http.StatusUnprocessableEntity, gin.H{
+            "error": err.Error(),
+        })
+        return
+    }
+    if !delivery.Success {
+        h.renderDeliveries(c, http.StatusOK, gin.H{
+            "error": "重新发送失败: " + delivery.Error,
+        })
+        return
+    }
+    c.Redirect(http.StatusSeeOther, "/webhooks?message=已重新发送")
+}
+
+// ListDeliveries 列出通知发送记录（JSON），支持 webhook 和 limit 参数
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+    if !h.permissions.IsAdmin(currentUser(c)) {
+        h.denyAPI(c, "webhook deliveries")
+        return
+    }
+    limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+    c.JSON(http.StatusOK, gin.H{
+        "success":    true,
+        "deliveries": h.webhooks.Deliveries(c.Query("webhook"), limit),
+    })
+}
+
+// ResendDelivery 重新发送通知（JSON），请求需为 application/json 或带有CSRF令牌请求头
+func (h *WebhookHandler) ResendDelivery(c *gin.Context) {
+    id := c.Param("id")
+    if !h.permissions.IsAdmin(currentUser(c)) {
+        h.denyAPI(c, "resend webhook delivery="+id)
+        return
+    }
+    // 请求没有请求体，浏览器跨站提交的表单无法设置 JSON 类型或自定义请求头
+    if mediaType(c) != contentTypeJSON && !verifyCSRF(c, h.csrf) {
+        logDenied(c, "resend webhook delivery="+id, fmt.Errorf("CSRF令牌无效"))
+        c.JSON(http.StatusForbidden, models.ProcessResponse{
+            Success: false,
+            Message: "请使用 Content-Type: application/json 或在 X-CSRF-Token 请求头中提供CSRF令牌",
+        })
+        return
+    }
+
+    delivery, err := h.webhooks.Resend(c.Request.Context(), id)
+    if err != nil {
+        c.JSON(http.StatusNotFound, models.ProcessResponse{
+            Success: false,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    message := "已重新发送"
+    if !delivery.Success {
+        message = "重新发送失败: " + delivery.Error
+    }
+    c.JSON(http.StatusOK, models.ProcessResponse{
+        Success: delivery.Success,
+        Message: message,
+        Data:    delivery,
+    })
+}
+
+// denyPage 非管理员访问通知记录页面
+func (h *WebhookHandler) denyPage(c *gin.Context, detail string) {
+    logDenied(c, detail, fmt.Errorf("需要管理员权限"))
+    c.HTML(http.StatusForbidden, "webhooks.html", gin.H{
+        "title": "VdSYMLinkTool - 通知记录",
+        "user":  currentUser(c),
+        "error": webhookAdminMessage,
+    })
+}
+
+// denyAPI 非管理员访问通知记录接口
+func (h *WebhookHandler) denyAPI(c *gin.Context, detail string) {
+    logDenied(c, detail, fmt.Errorf("需要管理员权限"))
+    c.JSON(http.StatusForbidden, models.ProcessResponse{
+        Success: false,
+        Message: webhookAdminMessage,
+    })
+}
+
+// renderDeliveries 渲染通知发送记录页面
+func (h *WebhookHandler) renderDeliveries(c *gin.Context, status int, data gin.H) {
+    data["title"] = "VdSYMLinkTool - 通知记录"
+    data["user"] = currentUser(c)
+    data["csrfToken"] = csrfToken(c, h.csrf)
+    data["deliveries"] = h.webhooks.Deliveries(c.Query("webhook"), 100)
+    c.HTML(status, "webhooks.html", data)
+}
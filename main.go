@@ -1,41 +1,307 @@
 package main
 
 import (
+    "context"
     "os"
     "fmt"
+    "path/filepath"
     "strconv"
+    "vdsymlink-web/config"
     "vdsymlink-web/handlers"
+    "vdsymlink-web/models"
+    "vdsymlink-web/services"
 
     "github.com/gin-gonic/gin"
 )
 
 func main() {
+    // 生成配置文件使用的密码哈希: ./main hash-password <密码>
+    if len(os.Args) == 3 && os.Args[1] == "hash-password" {
+        hash, err := services.HashPassword(os.Args[2])
+        if err != nil {
+            fmt.Printf("❌ 生成密码哈希失败: %v\n", err)
+            os.Exit(1)
+        }
+        fmt.Println(hash)
+        return
+    }
+    // 生成配置文件使用的API令牌哈希: ./main hash-token <令牌>
+    if len(os.Args) == 3 && os.Args[1] == "hash-token" {
+        fmt.Println(services.HashToken(os.Args[2]))
+        return
+    }
+    // 将媒体库迁移到其他命名模板: ./main migrate-naming <媒体库目录> <模板> [--apply]
+    if (len(os.Args) == 4 || len(os.Args) == 5) && os.Args[1] == "migrate-naming" {
+        runMigrateNaming(os.Args[2], os.Args[3], len(os.Args) == 5 && os.Args[4] == "--apply")
+        return
+    }
+
     port := getPort()
 
+    cfg, err := config.Load()
+    if err != nil {
+        fmt.Printf("❌ 加载配置失败: %v\n", err)
+        os.Exit(1)
+    }
+
     fmt.Printf("🚀 服务器启动在端口: %d\n", port)
     fmt.Printf("📎 访问地址: http://localhost:%d\n", port)
 
     router := gin.Default()
 
-    // 设置常用内网代理，解决GIN警告
-    router.SetTrustedProxies([]string{"127.0.0.1", "192.168.0.0/16", "10.0.0.0/8", "172.16.0.0/12"})
+    // 设置解析客户端IP时信任的代理，未配置时使用常用内网代理，解决GIN警告
+    router.SetTrustedProxies(cfg.ClientIPProxies())
 
     // 加载静态文件和模板
     router.Static("/static", "./static")
     router.LoadHTMLGlob("templates/*")
 
+    // 限制可访问的源目录和目标目录
+    guard := services.NewPathGuard(cfg.SourceRoots, cfg.TargetRoots)
+    if guard.Restricted(services.ScopeSource) {
+        fmt.Printf("🔒 源目录限制: %v\n", guard.Roots(services.ScopeSource))
+    }
+    if guard.Restricted(services.ScopeTarget) {
+        fmt.Printf("🔒 目标目录限制: %v\n", guard.Roots(services.ScopeTarget))
+    }
+
+    auth, err := services.NewAuthService(cfg.Auth, cfg.TrustedProxies)
+    if err != nil {
+        fmt.Printf("❌ 初始化认证失败: %v\n", err)
+        os.Exit(1)
+    }
+    if auth.Enabled() {
+        fmt.Println("🔑 已启用认证")
+    }
+
+    permissions, err := services.NewPermissionService(cfg.Auth.Roles)
+    if err != nil {
+        fmt.Printf("❌ 初始化角色权限失败: %v\n", err)
+        os.Exit(1)
+    }
+
+    csrf, err := services.NewCSRFService()
+    if err != nil {
+        fmt.Printf("❌ 初始化CSRF保护失败: %v\n", err)
+        os.Exit(1)
+    }
+
+    mapper, err := services.NewPathMapper(cfg.PathMaps)
+    if err != nil {
+        fmt.Printf("❌ 加载路径映射失败: %v\n", err)
+        os.Exit(1)
+    }
+
+    // 在容器中运行时，检测源目录和目标目录的绑定挂载，生成 host 路径映射
+    mounts := services.NewMountService()
+    if detected, err := mounts.Mappings(); err == nil {
+        for _, mapping := range detected {
+            fmt.Printf("💽 检测到绑定挂载: %s <- 宿主机 %s\n", mapping.From, mapping.To)
+        }
+        mapper.AddDetected(detected)
+    }
+    for _, name := range mapper.Names() {
+        fmt.Printf("🔀 路径映射: %s\n", name)
+    }
+
+    history, err := services.NewHistoryStore(cfg.DataDir, cfg.HistoryLimit)
+    if err != nil {
+        fmt.Printf("❌ 打开任务历史失败: %v\n", err)
+        os.Exit(1)
+    }
+    defer history.Close()
+    naming, err := services.LookupNaming(cfg.Naming)
+    if err != nil {
+        fmt.Printf("❌ 加载命名模板失败: %v\n", err)
+        os.Exit(1)
+    }
+    fmt.Printf("🏷️ 命名模板: %s\n", naming.Name)
+    manifest := services.NewManifestStore(history, cfg.Xattr)
+    symlinkService := services.NewSymlinkService(mapper, manifest, naming, cfg.StrictLinks)
+    jobs := services.NewJobManager(symlinkService, services.NewJobStore(), history, cfg.Workers, cfg.QueueSize)
+
+    notifier, err := services.NewMediaNotifier(cfg.MediaServers)
+    if err != nil {
+        fmt.Printf("❌ 加载媒体服务器配置失败: %v\n", err)
+        os.Exit(1)
+    }
+    jobs.SetNotifier(notifier)
+    for _, server := range cfg.MediaServers {
+        fmt.Printf("📺 任务成功后通知媒体服务器刷新: %s (%s)\n", server.URL, server.Type)
+    }
+
+    webhooks, err := services.NewWebhookService(cfg.Webhooks, cfg.PublicURL, cfg.DataDir)
+    if err != nil {
+        fmt.Printf("❌ 加载通知配置失败: %v\n", err)
+        os.Exit(1)
+    }
+    jobs.SetWebhooks(webhooks)
+    for _, webhook := range cfg.Webhooks {
+        fmt.Printf("🔔 任务事件通知: %s\n", webhook.URL)
+    }
+
+    library := services.NewLibraryService(mapper, history, manifest)
+    jobs.SetLibrary(library)
+
+    // 监视下载目录，下载完成后自动处理
+    if len(cfg.Watch.Rules) > 0 {
+        watcher, err := services.NewWatchService(cfg.Watch, guard, mapper, jobs, cfg.DataDir)
+        if err != nil {
+            fmt.Printf("❌ 初始化监视目录失败: %v\n", err)
+            os.Exit(1)
+        }
+        for _, rule := range cfg.Watch.Rules {
+            fmt.Printf("👀 监视目录 (%s): %s -> %s\n", watcher.Method(), rule.SourceRoot, rule.TargetDir)
+        }
+        go watcher.Run(context.Background())
+    }
+
+    profiles, err := services.NewProfileService(cfg.Profiles, guard, mapper)
+    if err != nil {
+        fmt.Printf("❌ 加载处理配置失败: %v\n", err)
+        os.Exit(1)
+    }
+
+    // 定期从 qBittorrent 拉取已完成的种子
+    if cfg.QBittorrent.URL != "" {
+        poller, err := services.NewQBittorrentPoller(cfg.QBittorrent, guard, profiles, jobs, cfg.DataDir)
+        if err != nil {
+            fmt.Printf("❌ 初始化 qBittorrent 拉取失败: %v\n", err)
+            os.Exit(1)
+        }
+        fmt.Printf("📡 qBittorrent 拉取: %s 分类 %v\n", cfg.QBittorrent.URL, poller.Categories())
+        go poller.Run(context.Background())
+    }
+
+    imports, err := services.NewImportService(symlinkService, jobs, cfg.DataDir)
+    if err != nil {
+        fmt.Printf("❌ 加载批量导入记录失败: %v\n", err)
+        os.Exit(1)
+    }
+
     // 初始化处理器
-    symlinkHandler := handlers.NewSymlinkHandler()
+    symlinkHandler := handlers.NewSymlinkHandler(jobs, guard, mapper, permissions, csrf)
+    jobHandler := handlers.NewJobHandler(jobs, guard, permissions, csrf)
+    authHandler := handlers.NewAuthHandler(auth, csrf)
+    hookHandler := handlers.NewHookHandler(jobs, guard, profiles, cfg.Hooks.Secret)
+    webhookHandler := handlers.NewWebhookHandler(webhooks, permissions, csrf)
+    mountHandler := handlers.NewMountHandler(mounts, guard, permissions)
+    libraryHandler := handlers.NewLibraryHandler(library, jobs, guard, permissions)
+    migrationHandler := handlers.NewMigrationHandler(symlinkService, jobs, guard, permissions)
+    importHandler := handlers.NewImportHandler(imports, guard, mapper, permissions, csrf)
 
     // 路由设置
-    router.GET("/", symlinkHandler.GetIndex)
-    router.POST("/api/process", symlinkHandler.ProcessFiles)
-    router.GET("/api/directories", symlinkHandler.ListDirectories)
+    router.Use(authHandler.DetectHTTPS())
+    router.GET("/login", authHandler.GetLogin)
+    router.POST("/login", authHandler.PostLogin)
+
+    // 下载器回调使用共享密钥认证
+    router.POST("/api/hooks/torrent-complete", hookHandler.TorrentComplete)
+
+    protected := router.Group("/", authHandler.RequireAuth())
+    protected.POST("/logout", authHandler.Logout)
+    protected.GET("/", symlinkHandler.GetIndex)
+    protected.POST("/api/process", symlinkHandler.ProcessFiles)
+    protected.POST("/api/process/batch", symlinkHandler.ProcessBatch)
+    protected.GET("/api/directories", symlinkHandler.ListDirectories)
+    protected.GET("/api/mounts", mountHandler.ListMounts)
+    protected.GET("/api/library/scan", libraryHandler.ScanLibrary)
+    protected.POST("/api/library/repair", libraryHandler.RepairLibrary)
+    protected.GET("/api/library/references", libraryHandler.ListReferences)
+    protected.GET("/api/library/references/counts", libraryHandler.ReferenceCounts)
+    protected.GET("/api/library/migration", migrationHandler.PlanMigration)
+    protected.POST("/api/library/migration", migrationHandler.Migrate)
+    protected.GET("/import", importHandler.GetImport)
+    protected.POST("/import", importHandler.PostImport)
+    protected.GET("/import/:id", importHandler.GetImportReport)
+    protected.GET("/api/import", importHandler.ListImports)
+    protected.GET("/api/import/plan", importHandler.PlanImport)
+    protected.POST("/api/import", importHandler.StartImport)
+    protected.GET("/api/import/:id", importHandler.GetImportStatus)
+    protected.DELETE("/api/import/:id", importHandler.CancelImport)
+    protected.GET("/jobs/:id", jobHandler.GetJob)
+    protected.POST("/jobs/:id/undo", jobHandler.UndoJob)
+    protected.GET("/history", jobHandler.GetHistory)
+    protected.GET("/api/history", jobHandler.ListHistory)
+    protected.GET("/api/jobs", jobHandler.ListJobs)
+    protected.GET("/api/jobs/:id", jobHandler.GetJobStatus)
+    protected.GET("/api/jobs/:id/events", jobHandler.StreamJobEvents)
+    protected.DELETE("/api/jobs/:id", jobHandler.CancelJob)
+    protected.GET("/webhooks", webhookHandler.GetDeliveries)
+    protected.POST("/webhooks/deliveries/:id/resend", webhookHandler.ResendDeliveryForm)
+    protected.GET("/api/webhooks/deliveries", webhookHandler.ListDeliveries)
+    protected.POST("/api/webhooks/deliveries/:id/resend", webhookHandler.ResendDelivery)
 
     // 启动服务器
     router.Run(":" + strconv.Itoa(port))
 }
 
+// runMigrateNaming 在命令行中显示媒体库迁移到命名模板的计划，apply 时执行迁移并记录到任务历史（可在页面中撤销）
+// 执行迁移前请停止服务，避免同时修改任务历史和媒体库清单
+func runMigrateNaming(root, name string, apply bool) {
+    cfg, err := config.Load()
+    if err != nil {
+        fmt.Printf("❌ 加载配置失败: %v\n", err)
+        os.Exit(1)
+    }
+    naming, err := services.LookupNaming(name)
+    if err != nil {
+        fmt.Printf("❌ %v\n", err)
+        os.Exit(1)
+    }
+    root, err = filepath.Abs(root)
+    if err != nil {
+        fmt.Printf("❌ 无法获取绝对路径: %v\n", err)
+        os.Exit(1)
+    }
+    mapper, err := services.NewPathMapper(cfg.PathMaps)
+    if err != nil {
+        fmt.Printf("❌ 加载路径映射失败: %v\n", err)
+        os.Exit(1)
+    }
+    history, err := services.NewHistoryStore(cfg.DataDir, cfg.HistoryLimit)
+    if err != nil {
+        fmt.Printf("❌ 打开任务历史失败: %v\n", err)
+        os.Exit(1)
+    }
+    defer history.Close()
+    current, _ := services.LookupNaming(cfg.Naming)
+    symlinkService := services.NewSymlinkService(mapper, services.NewManifestStore(history, cfg.Xattr), current, cfg.StrictLinks)
+
+    if !apply {
+        plan, err := symlinkService.PlanMigration(context.Background(), root, naming)
+        if err != nil {
+            fmt.Printf("❌ 生成迁移计划失败: %v\n", err)
+            os.Exit(1)
+        }
+        for _, item := range plan.Items {
+            if item.Conflict != "" {
+                fmt.Printf("⚠️ [%s] %s -> %s（%s）\n", item.Kind, item.From, item.To, item.Conflict)
+            } else {
+                fmt.Printf("[%s] %s -> %s\n", item.Kind, item.From, item.To)
+            }
+        }
+        fmt.Printf("📋 共 %d 项，冲突 %d 项，已符合模板 %d 项。确认后加上 --apply 执行迁移\n", len(plan.Items), plan.Conflicts, plan.Unchanged)
+        return
+    }
+
+    jobs := services.NewJobManager(symlinkService, services.NewJobStore(), history, 1, 1)
+    req := models.ProcessRequest{TargetDir: root, Mode: "migrate", Naming: naming.Name}
+    job, err := jobs.Run(context.Background(), req, nil, services.ProcessOptions{TargetDir: root, Mode: "migrate", Naming: naming.Name})
+    if err != nil {
+        fmt.Printf("❌ 迁移失败: %v\n", err)
+        os.Exit(1)
+    }
+    if job.Result != nil {
+        fmt.Print(job.Result.Log)
+    }
+    if job.Error != "" {
+        fmt.Printf("❌ 迁移失败: %s\n", job.Error)
+        os.Exit(1)
+    }
+    fmt.Printf("✅ 迁移任务 %s 已记录到任务历史，可在任务页面撤销\n", job.ID)
+}
+
 // getPort 从环境变量获取端口号
 func getPort() int {
     // 按优先级尝试不同的环境变量
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// 批量导入候选文件夹的类型
+const (
+    ImportSeries = "series" // 直接包含多个视频文件的剧集文件夹
+    ImportSeason = "season" // 剧集文件夹中的季子文件夹
+    ImportMovie  = "movie"  // 只包含一个视频文件的电影文件夹
+)
+
+// ImportCandidate 下载目录中可以导入的文件夹及预计的处理结果
+type ImportCandidate struct {
+    SourceDir  string `json:"sourceDir"`
+    TargetDir  string `json:"targetDir"` // 处理请求使用的目标目录
+    Kind       string `json:"kind"`      // series、season 或 movie
+    SeriesName string `json:"seriesName"`
+    Season     string `json:"season,omitempty"`
+    Files      int    `json:"files"`    // 视频文件数
+    FinalDir   string `json:"finalDir"` // 链接或文件最终所在的目录
+    Exists     bool   `json:"exists"`   // 目标季目录或电影文件已存在，可能已导入
+}
+
+// ImportPlan 批量导入下载目录的计划
+type ImportPlan struct {
+    Root       string            `json:"root"`
+    TargetDir  string            `json:"targetDir"`
+    Candidates []ImportCandidate `json:"candidates"`
+}
+
+// ImportRequest 批量导入请求，Sources 为空时导入所有目标尚不存在的候选文件夹
+type ImportRequest struct {
+    Root        string   `json:"root"`
+    TargetDir   string   `json:"targetDir"`
+    Mode        string   `json:"mode"` // "link" 或 "move"
+    PathMap     string   `json:"pathMap"`
+    StrictLinks bool     `json:"strictLinks"`
+    Sync        bool     `json:"sync"`
+    Sources     []string `json:"sources"` // 选择导入的候选文件夹
+}
+
+// ImportItem 批量导入中的一个文件夹及其任务
+type ImportItem struct {
+    ImportCandidate
+    JobID     string `json:"jobId,omitempty"`
+    Error     string `json:"error,omitempty"`     // 无法创建任务的原因
+    Cancelled bool   `json:"cancelled,omitempty"` // 加入队列前批量导入已取消
+}
+
+// ImportBatch 一次批量导入，每个文件夹作为一个任务加入队列
+type ImportBatch struct {
+    ID        string       `json:"id"`
+    Root      string       `json:"root"`
+    TargetDir string       `json:"targetDir"`
+    Mode      string       `json:"mode"`
+    User      string       `json:"user,omitempty"`
+    Items     []ImportItem `json:"items"`
+    CreatedAt time.Time    `json:"createdAt"`
+}
+
+// ImportItemReport 批量导入中一个文件夹的处理结果
+type ImportItemReport struct {
+    ImportItem
+    Status    string `json:"status"` // 任务状态，尚未加入队列时为 pending
+    Processed int    `json:"processed"`
+    Failed    int    `json:"failed"`
+}
+
+// ImportReport 批量导入的汇总报告
+type ImportReport struct {
+    ID          string             `json:"id"`
+    Root        string             `json:"root"`
+    TargetDir   string             `json:"targetDir"`
+    Mode        string             `json:"mode"`
+    User        string             `json:"user,omitempty"`
+    CreatedAt   time.Time          `json:"createdAt"`
+    Done        bool               `json:"done"` // 所有任务都已结束
+    Total       int                `json:"total"`
+    Succeeded   int                `json:"succeeded"`
+    Failed      int                `json:"failed"` // 失败或无法创建任务的文件夹数
+    Cancelled   int                `json:"cancelled"`
+    Pending     int                `json:"pending"` // 排队中、等待中或运行中
+    Processed   int                `json:"processed"`   // 成功处理的文件总数
+    FailedFiles int                `json:"failedFiles"` // 处理失败的文件总数
+    Items       []ImportItemReport `json:"items"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// 任务状态
+const (
+    JobQueued    = "queued"
+    JobWaiting   = "waiting" // 等待其他任务释放目录
+    JobRunning   = "running"
+    JobSucceeded = "succeeded"
+    JobFailed    = "failed"
+    JobCancelled = "cancelled"
+)
+
+// JobProgress 任务进度
+type JobProgress struct {
+    Total     int    `json:"total"`     // 待处理文件数
+    Completed int    `json:"completed"` // 已完成（含跳过和失败）
+    Succeeded int    `json:"succeeded"`
+    Skipped   int    `json:"skipped"`
+    Failed    int    `json:"failed"`
+    Cancelled int    `json:"cancelled"` // 因取消而未处理
+    Current   string `json:"current,omitempty"` // 最近处理的文件
+}
+
+// Job 一次处理任务及其结果
+type Job struct {
+    ID            string         `json:"id"`
+    Request       ProcessRequest `json:"request"`
+    User          string         `json:"user,omitempty"`
+    Status        string         `json:"status"`
+    Progress      JobProgress    `json:"progress"`
+    Result        *ProcessResult `json:"result,omitempty"`
+    Error         string         `json:"error,omitempty"`
+    WaitingFor    string         `json:"waitingFor,omitempty"`    // 正在等待的任务ID
+    Notifications []Notification `json:"notifications,omitempty"` // 媒体库刷新通知结果
+    UndoOf        string         `json:"undoOf,omitempty"`        // 撤销的原任务ID
+    UndoneBy      string         `json:"undoneBy,omitempty"`      // 撤销该任务的任务ID
+    CreatedAt     time.Time      `json:"createdAt"`
+    StartedAt     time.Time      `json:"startedAt"`
+    FinishedAt    time.Time      `json:"finishedAt"`
+}
+
+// Notification 媒体服务器刷新通知结果
+type Notification struct {
+    Server   string    `json:"server"`
+    Path     string    `json:"path"` // 媒体服务器中刷新的路径
+    Success  bool      `json:"success"`
+    Attempts int       `json:"attempts"`
+    Error    string    `json:"error,omitempty"`
+    At       time.Time `json:"at"`
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+    return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// 任务事件类型
+const (
+    EventProgress  = "progress"  // 进度更新
+    EventOperation = "operation" // 单个文件处理完成
+    EventLog       = "log"       // 日志输出
+    EventBytes     = "bytes"     // 复制文件的字节进度
+    EventStatus    = "status"    // 任务状态变化
+)
+
+// ByteProgress 复制文件的字节进度
+type ByteProgress struct {
+    File   string `json:"file"`
+    Copied int64  `json:"copied"`
+    Total  int64  `json:"total"`
+}
+
+// JobEvent 任务执行过程中推送的事件
+type JobEvent struct {
+    Type      string         `json:"type"`
+    Progress  *JobProgress   `json:"progress,omitempty"`
+    Operation *FileOperation `json:"operation,omitempty"`
+    Message   string         `json:"message,omitempty"`
+    Bytes     *ByteProgress  `json:"bytes,omitempty"`
+    Job       *Job           `json:"job,omitempty"`
+}
+
+// HistoryPage 任务历史的一页
+type HistoryPage struct {
+    Jobs     []*Job `json:"jobs"`
+    Total    int    `json:"total"`
+    Page     int    `json:"page"`
+    PageSize int    `json:"pageSize"`
+    Pages    int    `json:"pages"`
+}
@@ -0,0 +1,84 @@
+package models
+
+// 断链修复操作
+const (
+    RepairDelete  = "delete"  // 删除断开的链接
+    RepairRepoint = "repoint" // 将链接指向源目录中找到的文件
+)
+
+// BrokenLink 目标目录中无法解析的符号链接
+type BrokenLink struct {
+    Path        string          `json:"path"`                  // 符号链接路径
+    LinkTarget  string          `json:"linkTarget"`            // 链接指向的路径
+    LocalTarget string          `json:"localTarget,omitempty"` // 通过路径映射转换后的本机路径
+    PathMap     string          `json:"pathMap,omitempty"`     // 链接目标匹配的路径映射
+    Size        int64           `json:"size,omitempty"`        // 历史记录中的源文件大小
+    Owner       string          `json:"owner,omitempty"`       // 创建链接的任务ID，为空时不是本工具创建的链接
+    Candidates  []LinkCandidate `json:"candidates"`            // 源目录中可能是移动后的文件
+}
+
+// LinkCandidate 断链可能指向的文件
+type LinkCandidate struct {
+    Path    string `json:"path"`
+    Size    int64  `json:"size"`
+    MatchBy string `json:"matchBy"` // name、name+size 或 size
+}
+
+// LibraryGroup 同一剧集同一季的断链
+type LibraryGroup struct {
+    Series string       `json:"series"`
+    Season string       `json:"season,omitempty"`
+    Links  []BrokenLink `json:"links"`
+}
+
+// LibraryScan 媒体库断链扫描结果
+type LibraryScan struct {
+    Root       string         `json:"root"`
+    SearchDirs []string       `json:"searchDirs"` // 查找移动后文件的源目录
+    Links      int            `json:"links"`      // 检查的符号链接数
+    Broken     int            `json:"broken"`
+    Groups     []LibraryGroup `json:"groups"`
+}
+
+// RepairRequest 断链修复请求，Links 为空时修复目录中所有断链
+type RepairRequest struct {
+    Root       string            `json:"root"`
+    Action     string            `json:"action"` // delete 或 repoint
+    Links      []string          `json:"links"`
+    Targets    map[string]string `json:"targets"`    // 链接路径 -> 指定的新源文件，未指定时使用唯一的候选文件
+    SearchDirs []string          `json:"searchDirs"` // 查找移动后文件的源目录，为空时使用源根目录
+    Force      bool              `json:"force"`      // 同时修复不是本工具创建的链接
+}
+
+// RepairResult 单个链接的修复结果
+type RepairResult struct {
+    Path       string `json:"path"`
+    Action     string `json:"action"`
+    LinkTarget string `json:"linkTarget,omitempty"` // 修复后链接指向的路径
+    Success    bool   `json:"success"`
+    Error      string `json:"error,omitempty"`
+}
+
+// 引用源文件的方式
+const (
+    ReferenceSymlink  = "symlink"
+    ReferenceHardlink = "hardlink" // 与源文件是同一个文件（inode 相同）
+    ReferenceStrm     = "strm"     // .strm 文件中的路径
+)
+
+// SourceReference 媒体库中引用源文件的链接或文件
+type SourceReference struct {
+    Path   string `json:"path"`             // 媒体库中的路径
+    Type   string `json:"type"`             // symlink、hardlink 或 strm
+    Source string `json:"source"`           // 被引用的源文件
+    Target string `json:"target,omitempty"` // 符号链接或 .strm 文件中记录的路径
+    JobID  string `json:"jobId,omitempty"`  // 创建的任务，为空时不是本工具创建的
+}
+
+// ReferenceReport 源文件或目录在媒体库中的引用
+type ReferenceReport struct {
+    Path       string            `json:"path"`
+    Roots      []string          `json:"roots"` // 查找的媒体库目录
+    Count      int               `json:"count"`
+    References []SourceReference `json:"references"`
+}
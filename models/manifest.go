@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ManifestEntry 媒体库清单中一个由本工具创建的链接或移动的文件
+type ManifestEntry struct {
+    JobID      string    `json:"jobId"`
+    Action     string    `json:"action"`               // "link" 或 "move"
+    Source     string    `json:"source,omitempty"`
+    LinkTarget string    `json:"linkTarget,omitempty"` // 创建时链接指向的路径
+    CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Manifest 保存在媒体库目录中的清单文件，记录本工具在该目录下创建的内容
+type Manifest struct {
+    Version int                      `json:"version"`
+    Entries map[string]ManifestEntry `json:"entries"` // 相对清单所在目录的路径
+}
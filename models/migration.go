@@ -0,0 +1,32 @@
+package models
+
+// 迁移计划中的项目类型
+const (
+    MigrationLink    = "link"    // 本工具创建的链接
+    MigrationFile    = "file"    // 本工具移动到媒体库的文件
+    MigrationSidecar = "sidecar" // 与剧集文件同名的字幕、NFO 等附属文件
+)
+
+// MigrationItem 迁移计划中的一次重命名
+type MigrationItem struct {
+    From     string `json:"from"`
+    To       string `json:"to"`
+    Kind     string `json:"kind"`               // link、file 或 sidecar
+    Conflict string `json:"conflict,omitempty"` // 无法迁移的原因
+}
+
+// MigrationPlan 将媒体库中本工具管理的文件迁移到另一个命名模板的计划
+type MigrationPlan struct {
+    Root      string          `json:"root"`
+    Naming    string          `json:"naming"`
+    Items     []MigrationItem `json:"items"`
+    Conflicts int             `json:"conflicts"`
+    Unchanged int             `json:"unchanged"` // 已符合目标模板的剧集文件数
+}
+
+// MigrationRequest 命名模板迁移请求
+type MigrationRequest struct {
+    Root   string `json:"root"`
+    Naming string `json:"naming"`
+    Async  bool   `json:"async"`
+}
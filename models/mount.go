@@ -0,0 +1,20 @@
+package models
+
+// Mount 从 /proc/self/mountinfo 检测到的挂载点
+type Mount struct {
+    MountPoint string `json:"mountPoint"`         // 容器内的挂载路径
+    HostPath   string `json:"hostPath,omitempty"` // 宿主机上的路径（绑定挂载时为挂载的子目录）
+    Source     string `json:"source"`             // 挂载来源设备
+    FSType     string `json:"fsType"`
+    Bind       bool   `json:"bind"`     // 是否为子目录的绑定挂载
+    Verified   bool   `json:"verified"` // 已确认磁盘在宿主机上的挂载点；为 false 时 HostPath 是磁盘内的路径，仅当磁盘挂载在宿主机根目录时正确
+}
+
+// MountSuggestion 路径所在的挂载点和建议的重定向路径
+type MountSuggestion struct {
+    Path         string `json:"path"`
+    Mount        *Mount `json:"mount,omitempty"`
+    HostPath     string `json:"hostPath,omitempty"`     // 路径在宿主机上的位置
+    RedirectPath string `json:"redirectPath,omitempty"` // 使用该路径作为视频目录时建议的重定向路径
+    Guess        bool   `json:"guess,omitempty"`        // 挂载点未确认，宿主机路径和重定向路径是假定磁盘挂载在宿主机根目录时的推测
+}
@@ -5,10 +5,95 @@ type ProcessRequest struct {
     TargetDir    string `json:"targetDir"`
     Mode         string `json:"mode" binding:"required"` // "link", "move", "rename"
     RedirectPath string `json:"redirectPath"` // 重定向路径，用于Docker环境
+    PathMap      string `json:"pathMap"`      // 路径映射名称，为空时使用 default 映射
+    StrictLinks  bool   `json:"strictLinks"`  // 拒绝创建无法验证的链接
+    Sync         bool   `json:"sync"`         // 同步目标季目录，删除源文件已不存在的链接
+    Naming       string `json:"naming,omitempty"` // 命名模板，为空时使用配置的模板；迁移模式下为迁移的目标模板
+    Async        bool   `json:"async"`        // 加入任务队列后立即返回任务ID
+}
+
+// TorrentHookRequest 下载器在种子下载完成时的回调参数
+type TorrentHookRequest struct {
+    ContentPath string `json:"contentPath" form:"contentPath"` // 种子内容路径（多文件种子为目录，单文件种子为文件）
+    Category    string `json:"category" form:"category"`
+    Name        string `json:"name" form:"name"`
+    Hash        string `json:"hash" form:"hash"`
+}
+
+// 批量处理中未执行的请求状态，执行的请求使用任务状态
+const (
+    BatchInvalid = "invalid" // 参数错误或权限不足
+    BatchSkipped = "skipped" // 指定 stopOnError 时因其他请求失败而未执行
+)
+
+// 批量处理的整体状态
+const (
+    BatchSucceeded = "succeeded" // 所有请求都成功
+    BatchPartial   = "partial"   // 部分请求成功
+    BatchFailed    = "failed"    // 没有成功的请求
+)
+
+// BatchItemResult 批量处理中一个请求的结果
+type BatchItemResult struct {
+    Index      int            `json:"index"` // 请求在批量请求中的位置，从 0 开始
+    Request    ProcessRequest `json:"request"`
+    Status     string         `json:"status"`               // 任务状态，或 invalid、skipped
+    StatusCode int            `json:"statusCode,omitempty"` // 参数错误时单独请求 /api/process 返回的状态码
+    JobID      string         `json:"jobId,omitempty"`
+    Error      string         `json:"error,omitempty"`
+    Result     *ProcessResult `json:"result,omitempty"`
+}
+
+// BatchResponse 批量处理的结果
+type BatchResponse struct {
+    Success   bool              `json:"success"`
+    Status    string            `json:"status"` // succeeded、partial 或 failed
+    Total     int               `json:"total"`
+    Succeeded int               `json:"succeeded"`
+    Failed    int               `json:"failed"` // 失败、取消或参数错误的请求数
+    Skipped   int               `json:"skipped"`
+    Items     []BatchItemResult `json:"items"`
 }
 
 type ProcessResponse struct {
     Success bool   `json:"success"`
     Message string `json:"message"`
     Data    any    `json:"data,omitempty"`
-}
\ No newline at end of file
+    JobID   string `json:"jobId,omitempty"`
+}
+
+// 文件操作状态
+const (
+    OperationDone      = "done"
+    OperationSkipped   = "skipped"
+    OperationFailed    = "failed"
+    OperationCancelled = "cancelled" // 任务取消时未处理的文件
+)
+
+// FileOperation 单个文件的处理记录
+type FileOperation struct {
+    Action         string `json:"action"`                   // "link", "move", "rename", "unlink"（同步模式删除的链接）
+    Source         string `json:"source"`
+    Target         string `json:"target"`
+    LinkTarget     string `json:"linkTarget,omitempty"`     // 符号链接实际指向的路径（可能经过重定向）
+    Status         string `json:"status"`
+    Error          string `json:"error,omitempty"`
+    Size           int64  `json:"size,omitempty"`           // 源文件大小，用于源文件移动后按大小查找
+    ReplacedTarget string `json:"replacedTarget,omitempty"` // 被替换的旧链接指向的路径，撤销时恢复
+    Verified       bool   `json:"verified,omitempty"`       // 链接已验证可解析回源文件
+    VerifyError    string `json:"verifyError,omitempty"`    // 链接无法验证的原因（悬空链接）
+    Owner          string `json:"owner,omitempty"`          // 删除或替换的链接的创建任务，撤销时恢复清单记录
+}
+
+// ProcessResult 一次处理的结构化结果
+type ProcessResult struct {
+    SeriesName string          `json:"seriesName"`
+    Season     string          `json:"season"`
+    TargetDir  string          `json:"targetDir"` // 最终目标目录
+    Processed  int             `json:"processed"`
+    Failed     int             `json:"failed"`
+    Dangling   int             `json:"dangling,omitempty"` // 无法验证的链接数
+    Removed    int             `json:"removed,omitempty"`  // 同步模式删除的过期链接数
+    Operations []FileOperation `json:"operations"`
+    Log        string          `json:"log"`
+}
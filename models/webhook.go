@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// 任务事件
+const (
+    WebhookStarted   = "started"
+    WebhookSucceeded = "succeeded"
+    WebhookPartial   = "partial" // 任务完成但部分文件失败
+    WebhookFailed    = "failed"
+    WebhookCancelled = "cancelled"
+)
+
+// WebhookPayload 通知模板可使用的任务信息
+type WebhookPayload struct {
+    Event      string    `json:"event"`
+    JobID      string    `json:"jobId"`
+    JobURL     string    `json:"jobUrl,omitempty"`
+    Status     string    `json:"status"`
+    Mode       string    `json:"mode"`
+    User       string    `json:"user,omitempty"`
+    SourceDir  string    `json:"sourceDir"`
+    TargetDir  string    `json:"targetDir,omitempty"`
+    Series     string    `json:"series,omitempty"`
+    Season     string    `json:"season,omitempty"`
+    Processed  int       `json:"processed"`
+    Failed     int       `json:"failed"`
+    Skipped    int       `json:"skipped"`
+    Removed    int       `json:"removed,omitempty"` // 同步模式删除的过期链接数
+    Files      []string  `json:"files,omitempty"`  // 处理成功的目标文件名
+    Errors     []string  `json:"errors,omitempty"` // 处理失败的文件和原因
+    Error      string    `json:"error,omitempty"`  // 任务错误
+    StartedAt  time.Time `json:"startedAt"`
+    FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+// WebhookDelivery 一次通知发送记录
+type WebhookDelivery struct {
+    ID          string    `json:"id"`
+    Webhook     string    `json:"webhook"`
+    Event       string    `json:"event"`
+    JobID       string    `json:"jobId"`
+    URL         string    `json:"url"`
+    ContentType string    `json:"contentType"`
+    Body        string    `json:"body"`
+    Success     bool      `json:"success"`
+    StatusCode  int       `json:"statusCode,omitempty"`
+    Response    string    `json:"response,omitempty"` // 响应内容（截断）
+    Error       string    `json:"error,omitempty"`
+    Attempts    int       `json:"attempts"`
+    ResendOf    string    `json:"resendOf,omitempty"` // 手动重新发送的原记录ID
+    At          time.Time `json:"at"`
+}
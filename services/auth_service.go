@@ -0,0 +1,265 @@
+package services
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "time"
+    "vdsymlink-web/config"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// User 已认证的用户
+type User struct {
+    Username string `json:"username"`
+    Role     string `json:"role,omitempty"`
+    Method   string `json:"method"` // session, token, proxy
+}
+
+type session struct {
+    username  string
+    expiresAt time.Time
+}
+
+type apiToken struct {
+    name string
+    user string
+    role string
+    hash []byte
+}
+
+// AuthService 用户认证：登录会话、API令牌和反向代理请求头
+type AuthService struct {
+    users          map[string][]byte
+    userRoles      map[string]string
+    defaultRole    string
+    dummyHash      []byte
+    tokens         []apiToken
+    proxyHeader    string
+    trustedProxies []*net.IPNet
+    sessionTTL     time.Duration
+
+    mu       sync.Mutex
+    sessions map[string]session
+}
+
+func NewAuthService(cfg config.AuthConfig, trustedProxies []string) (*AuthService, error) {
+    s := &AuthService{
+        users:       make(map[string][]byte),
+        userRoles:   make(map[string]string),
+        defaultRole: cfg.DefaultRole,
+        proxyHeader: cfg.ProxyHeader,
+        sessionTTL:  time.Duration(cfg.SessionHours) * time.Hour,
+        sessions:    make(map[string]session),
+    }
+    if s.sessionTTL <= 0 {
+        s.sessionTTL = 24 * time.Hour
+    }
+
+    for _, user := range cfg.Users {
+        if user.Username == "" {
+            continue
+        }
+        hash := []byte(user.PasswordHash)
+        if len(hash) == 0 {
+            if user.Password == "" {
+                return nil, fmt.Errorf("用户 %s 未设置密码", user.Username)
+            }
+            var err error
+            if hash, err = bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost); err != nil {
+                return nil, fmt.Errorf("无法哈希用户 %s 的密码: %v", user.Username, err)
+            }
+        }
+        s.users[user.Username] = hash
+        s.userRoles[user.Username] = user.Role
+    }
+    if len(s.users) > 0 {
+        s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("vdsymlink"), bcrypt.DefaultCost)
+    }
+
+    for _, token := range cfg.Tokens {
+        hash, err := hex.DecodeString(token.TokenHash)
+        if token.Token != "" {
+            sum := sha256.Sum256([]byte(token.Token))
+            hash, err = sum[:], nil
+        }
+        if err != nil || len(hash) != sha256.Size {
+            return nil, fmt.Errorf("API令牌 %s 的哈希格式错误", token.Name)
+        }
+        s.tokens = append(s.tokens, apiToken{name: token.Name, user: token.User, role: token.Role, hash: hash})
+    }
+
+    for _, proxy := range trustedProxies {
+        network, err := parseNetwork(proxy)
+        if err != nil {
+            return nil, fmt.Errorf("无效的代理地址 %s: %v", proxy, err)
+        }
+        s.trustedProxies = append(s.trustedProxies, network)
+    }
+
+    return s, nil
+}
+
+// 解析IP或CIDR
+func parseNetwork(value string) (*net.IPNet, error) {
+    if !strings.Contains(value, "/") {
+        ip := net.ParseIP(value)
+        if ip == nil {
+            return nil, fmt.Errorf("无法解析IP")
+        }
+        bits := 32
+        if ip.To4() == nil {
+            bits = 128
+        }
+        value = fmt.Sprintf("%s/%d", value, bits)
+    }
+    _, network, err := net.ParseCIDR(value)
+    return network, err
+}
+
+// HashPassword 生成 bcrypt 密码哈希，用于编写配置文件
+func HashPassword(password string) (string, error) {
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    return string(hash), err
+}
+
+// HashToken 生成API令牌的 SHA-256 哈希，用于编写配置文件
+func HashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// Enabled 是否启用认证
+func (s *AuthService) Enabled() bool {
+    return len(s.users) > 0 || len(s.tokens) > 0 || s.proxyHeader != ""
+}
+
+// ProxyHeader 受信任的用户名请求头
+func (s *AuthService) ProxyHeader() string {
+    return s.proxyHeader
+}
+
+// SessionTTL 会话有效期
+func (s *AuthService) SessionTTL() time.Duration {
+    return s.sessionTTL
+}
+
+// Login 校验用户名和密码，成功后创建会话
+func (s *AuthService) Login(username, password string) (string, error) {
+    hash, ok := s.users[username]
+    if !ok {
+        // 仍然执行一次比较，避免通过响应时间判断用户是否存在
+        bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+        return "", fmt.Errorf("用户名或密码错误")
+    }
+    if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+        return "", fmt.Errorf("用户名或密码错误")
+    }
+
+    id, err := randomToken()
+    if err != nil {
+        return "", fmt.Errorf("无法创建会话: %v", err)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.pruneSessions()
+    s.sessions[id] = session{username: username, expiresAt: time.Now().Add(s.sessionTTL)}
+    return id, nil
+}
+
+// Session 根据会话ID查找用户
+func (s *AuthService) Session(id string) (*User, bool) {
+    if id == "" {
+        return nil, false
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    sess, ok := s.sessions[id]
+    if !ok || time.Now().After(sess.expiresAt) {
+        delete(s.sessions, id)
+        return nil, false
+    }
+    return s.newUser(sess.username, "", "session"), true
+}
+
+// Logout 删除会话
+func (s *AuthService) Logout(id string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.sessions, id)
+}
+
+// AuthenticateToken 校验API令牌
+func (s *AuthService) AuthenticateToken(token string) (*User, bool) {
+    if token == "" {
+        return nil, false
+    }
+    sum := sha256.Sum256([]byte(token))
+    for _, t := range s.tokens {
+        if subtle.ConstantTimeCompare(sum[:], t.hash) == 1 {
+            username := t.user
+            if username == "" {
+                username = t.name
+            }
+            return s.newUser(username, t.role, "token"), true
+        }
+    }
+    return nil, false
+}
+
+// ProxyUser 根据受信任代理传入的用户名创建用户
+func (s *AuthService) ProxyUser(username string) *User {
+    return s.newUser(username, "", "proxy")
+}
+
+// 创建用户并确定角色：显式角色 > 用户配置的角色 > 默认角色
+func (s *AuthService) newUser(username, role, method string) *User {
+    if role == "" {
+        role = s.userRoles[username]
+    }
+    if role == "" {
+        role = s.defaultRole
+    }
+    return &User{Username: username, Role: role, Method: method}
+}
+
+// IsTrustedProxy 检查请求的直接来源是否为受信任的代理
+func (s *AuthService) IsTrustedProxy(remoteIP string) bool {
+    ip := net.ParseIP(remoteIP)
+    if ip == nil {
+        return false
+    }
+    for _, network := range s.trustedProxies {
+        if network.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+// 清理过期会话，调用方需持有锁
+func (s *AuthService) pruneSessions() {
+    now := time.Now()
+    for id, sess := range s.sessions {
+        if now.After(sess.expiresAt) {
+            delete(s.sessions, id)
+        }
+    }
+}
+
+// 生成随机令牌
+func randomToken() (string, error) {
+    buf := make([]byte, 32)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(buf), nil
+}
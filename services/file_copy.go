@@ -0,0 +1,109 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "syscall"
+)
+
+// 复制进度的最小推送间隔（字节）
+const copyProgressStep = 8 << 20
+
+// moveFile 移动文件，源和目标位于不同设备时复制后删除源文件
+func (s *SymlinkService) moveFile(ctx context.Context, source, target string, result *processRecorder) error {
+    err := os.Rename(source, target)
+    if err == nil || !errors.Is(err, syscall.EXDEV) {
+        return err
+    }
+
+    fmt.Fprintf(result, "跨设备移动，复制文件: %s\n", source)
+    if err := copyFile(ctx, source, target, func(copied, total int64) {
+        result.copyProgress(source, copied, total)
+    }); err != nil {
+        return err
+    }
+    return os.Remove(source)
+}
+
+// copyFile 复制文件内容、权限和修改时间，目标已存在时失败且不修改已存在的文件
+// 复制过程中出错或取消时删除本次创建的不完整目标文件
+func copyFile(ctx context.Context, source, target string, progress func(copied, total int64)) (err error) {
+    in, err := os.Open(source)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    info, err := in.Stat()
+    if err != nil {
+        return err
+    }
+
+    out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+    if err != nil {
+        return err
+    }
+    // 目标文件由本次复制创建，失败时删除
+    defer func() {
+        if err != nil {
+            os.Remove(target)
+        }
+    }()
+
+    writer := &progressWriter{total: info.Size(), progress: progress}
+    reader := &contextReader{ctx: ctx, reader: in}
+    if _, err := io.Copy(out, io.TeeReader(reader, writer)); err != nil {
+        out.Close()
+        return err
+    }
+    if err := out.Sync(); err != nil {
+        out.Close()
+        return err
+    }
+    if err := out.Close(); err != nil {
+        return err
+    }
+    writer.report()
+
+    return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
+
+// progressWriter 统计已复制的字节数并按间隔推送进度
+type progressWriter struct {
+    copied   int64
+    reported int64
+    total    int64
+    progress func(copied, total int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+    w.copied += int64(len(p))
+    if w.copied-w.reported >= copyProgressStep {
+        w.report()
+    }
+    return len(p), nil
+}
+
+// report 推送当前进度
+func (w *progressWriter) report() {
+    w.reported = w.copied
+    if w.progress != nil {
+        w.progress(w.copied, w.total)
+    }
+}
+
+// contextReader 在每次读取前检查是否已取消
+type contextReader struct {
+    ctx    context.Context
+    reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+    if err := r.ctx.Err(); err != nil {
+        return 0, err
+    }
+    return r.reader.Read(p)
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+    "vdsymlink-web/models"
+)
+
+// testEnv 测试用的源目录、目标目录和任务管理器
+type testEnv struct {
+    dir     string
+    source  string
+    target  string
+    guard   *PathGuard
+    mapper  *PathMapper
+    history *HistoryStore
+    service *SymlinkService
+    jobs    *JobManager
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+    t.Helper()
+    dir := t.TempDir()
+    env := &testEnv{
+        dir:    dir,
+        source: filepath.Join(dir, "src"),
+        target: filepath.Join(dir, "lib"),
+    }
+    for _, path := range []string{env.source, env.target} {
+        if err := os.MkdirAll(path, 0755); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    var err error
+    env.guard = NewPathGuard([]string{env.source}, []string{env.target})
+    if env.mapper, err = NewPathMapper(nil); err != nil {
+        t.Fatal(err)
+    }
+    if env.history, err = NewHistoryStore(filepath.Join(dir, "data"), 0); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { env.history.Close() })
+    naming, err := LookupNaming("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    env.service = NewSymlinkService(env.mapper, NewManifestStore(env.history, false), naming, false)
+    env.jobs = NewJobManager(env.service, NewJobStore(), env.history, 2, 10)
+    return env
+}
+
+// touch 创建空文件及其所在目录
+func touch(t *testing.T, paths ...string) {
+    t.Helper()
+    for _, path := range paths {
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+// waitJob 等待任务结束
+func waitJob(t *testing.T, jobs *JobManager, id string) *models.Job {
+    t.Helper()
+    deadline := time.Now().Add(10 * time.Second)
+    for time.Now().Before(deadline) {
+        if job, ok := jobs.Get(id); ok && job.Finished() {
+            return job
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    t.Fatalf("任务 %s 未在规定时间内结束", id)
+    return nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "vdsymlink-web/models"
+)
+
+// 任务历史文件名，每行一条任务记录（JSON），同一任务的后续记录覆盖之前的记录
+const historyFile = "jobs.jsonl"
+
+// 历史分页的默认和最大每页数量
+const (
+    defaultHistoryPageSize = 20
+    maxHistoryPageSize     = 200
+)
+
+// HistoryFilter 任务历史的筛选条件，为空的字段不过滤
+type HistoryFilter struct {
+    Series   string // 剧集名（包含，不区分大小写）
+    Mode     string
+    Status   string
+    User     string
+    File     string // 源文件或目标文件路径（包含，不区分大小写）
+    Page     int    // 从1开始
+    PageSize int
+    Visible  func(job *models.Job) bool // 用户可以查看的任务，为 nil 时不限制
+}
+
+// HistoryStore 将任务记录追加保存到数据目录中的文件，重启后仍可查询
+type HistoryStore struct {
+    mu    sync.RWMutex
+    path  string
+    file  *os.File
+    jobs  map[string]*models.Job
+    order []string                        // 按创建顺序
+    links map[string]models.ManifestEntry // 链接路径 -> 最近创建该链接的任务，用于清单功能之前创建的链接
+    lines int                             // 文件中的记录行数，用于判断是否需要压缩
+    limit int                             // 保留的任务数，超出时删除最早的已结束任务，0 表示不限制
+}
+
+// NewHistoryStore 打开数据目录中的任务历史，目录不存在时创建。limit 为保留的任务数，0 表示不限制
+func NewHistoryStore(dataDir string, limit int) (*HistoryStore, error) {
+    if err := os.MkdirAll(dataDir, 0755); err != nil {
+        return nil, fmt.Errorf("无法创建数据目录 %s: %v", dataDir, err)
+    }
+
+    s := &HistoryStore{
+        path: filepath.Join(dataDir, historyFile),
+        jobs:  make(map[string]*models.Job),
+        links: make(map[string]models.ManifestEntry),
+        limit: limit,
+    }
+    if err := s.load(); err != nil {
+        return nil, err
+    }
+    s.trim()
+
+    // 记录重复较多时重写文件，只保留每个任务的最新记录
+    if s.lines > 2*len(s.jobs)+100 {
+        if err := s.compact(); err != nil {
+            return nil, err
+        }
+    }
+
+    file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+    if err != nil {
+        return nil, fmt.Errorf("无法打开任务历史文件: %v", err)
+    }
+    s.file = file
+    return s, nil
+}
+
+// load 读取历史文件，服务停止时未结束的任务标记为失败
+func (s *HistoryStore) load() error {
+    file, err := os.Open(s.path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("无法读取任务历史文件: %v", err)
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+    for scanner.Scan() {
+        line := scanner.Bytes()
+        if len(line) == 0 {
+            continue
+        }
+        var job models.Job
+        if err := json.Unmarshal(line, &job); err != nil || job.ID == "" {
+            // 跳过写入中断产生的不完整记录
+            continue
+        }
+        s.lines++
+        s.put(&job)
+    }
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("无法读取任务历史文件: %v", err)
+    }
+
+    for _, job := range s.jobs {
+        if !job.Finished() {
+            job.Status = models.JobFailed
+            job.Error = "服务重启，任务中断"
+        }
+    }
+    return nil
+}
+
+// compact 重写历史文件
+func (s *HistoryStore) compact() error {
+    tmp := s.path + ".tmp"
+    file, err := os.Create(tmp)
+    if err != nil {
+        return fmt.Errorf("无法压缩任务历史文件: %v", err)
+    }
+
+    writer := bufio.NewWriter(file)
+    encoder := json.NewEncoder(writer)
+    for _, id := range s.order {
+        if err := encoder.Encode(s.jobs[id]); err != nil {
+            file.Close()
+            os.Remove(tmp)
+            return fmt.Errorf("无法压缩任务历史文件: %v", err)
+        }
+    }
+    if err := writer.Flush(); err != nil {
+        file.Close()
+        os.Remove(tmp)
+        return fmt.Errorf("无法压缩任务历史文件: %v", err)
+    }
+    if err := file.Close(); err != nil {
+        os.Remove(tmp)
+        return fmt.Errorf("无法压缩任务历史文件: %v", err)
+    }
+
+    s.lines = len(s.order)
+    return os.Rename(tmp, s.path)
+}
+
+// put 更新内存中的记录，调用方需持有 s.mu
+func (s *HistoryStore) put(job *models.Job) {
+    if _, exists := s.jobs[job.ID]; !exists {
+        s.order = append(s.order, job.ID)
+    }
+    s.jobs[job.ID] = job
+
+    if job.Result == nil {
+        return
+    }
+    for _, op := range job.Result.Operations {
+        if op.Action != "link" || op.Status != models.OperationDone {
+            continue
+        }
+        // 旧任务的记录更新（如通知结果）不覆盖较新任务创建的链接
+        if owner, ok := s.links[op.Target]; ok && owner.JobID != job.ID && owner.CreatedAt.After(job.CreatedAt) {
+            continue
+        }
+        s.links[op.Target] = models.ManifestEntry{
+            JobID:      job.ID,
+            Action:     op.Action,
+            Source:     op.Source,
+            LinkTarget: op.LinkTarget,
+            CreatedAt:  job.CreatedAt,
+        }
+    }
+}
+
+// trim 任务数超过限制时删除最早的已结束任务，文件中的记录在下次启动压缩时删除，调用方需持有 s.mu
+func (s *HistoryStore) trim() {
+    if s.limit <= 0 || len(s.order) <= s.limit {
+        return
+    }
+    excess := len(s.order) - s.limit
+    kept := s.order[:0]
+    for _, id := range s.order {
+        if excess > 0 && s.jobs[id].Finished() {
+            delete(s.jobs, id)
+            excess--
+            continue
+        }
+        kept = append(kept, id)
+    }
+    s.order = kept
+}
+
+// Record 保存任务的当前状态
+func (s *HistoryStore) Record(job *models.Job) error {
+    data, err := json.Marshal(job)
+    if err != nil {
+        return err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    copied := *job
+    s.put(&copied)
+    s.trim()
+    s.lines++
+    if _, err := s.file.Write(append(data, '\n')); err != nil {
+        return fmt.Errorf("无法写入任务历史: %v", err)
+    }
+    return nil
+}
+
+// Get 获取任务的副本
+func (s *HistoryStore) Get(id string) (*models.Job, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    job, ok := s.jobs[id]
+    if !ok {
+        return nil, false
+    }
+    copied := *job
+    return &copied, true
+}
+
+// Query 按创建时间倒序分页查询任务
+func (s *HistoryStore) Query(filter HistoryFilter) models.HistoryPage {
+    if filter.Page < 1 {
+        filter.Page = 1
+    }
+    if filter.PageSize <= 0 {
+        filter.PageSize = defaultHistoryPageSize
+    }
+    if filter.PageSize > maxHistoryPageSize {
+        filter.PageSize = maxHistoryPageSize
+    }
+
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    page := models.HistoryPage{
+        Jobs:     []*models.Job{},
+        Page:     filter.Page,
+        PageSize: filter.PageSize,
+    }
+    skip := (filter.Page - 1) * filter.PageSize
+    for i := len(s.order) - 1; i >= 0; i-- {
+        job := s.jobs[s.order[i]]
+        if !filter.matches(job) {
+            continue
+        }
+        page.Total++
+        if page.Total > skip && len(page.Jobs) < filter.PageSize {
+            copied := *job
+            page.Jobs = append(page.Jobs, &copied)
+        }
+    }
+    page.Pages = (page.Total + filter.PageSize - 1) / filter.PageSize
+    return page
+}
+
+// LinkSizes 历史任务创建的链接对应的源文件大小（目标路径 -> 大小），较新的记录优先
+func (s *HistoryStore) LinkSizes() map[string]int64 {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    sizes := make(map[string]int64)
+    for _, id := range s.order {
+        job := s.jobs[id]
+        if job.Result == nil {
+            continue
+        }
+        for _, op := range job.Result.Operations {
+            if op.Action == "link" && op.Status == models.OperationDone && op.Size > 0 {
+                sizes[op.Target] = op.Size
+            }
+        }
+    }
+    return sizes
+}
+
+// LinkOwner 查找创建链接的最近一次任务，用于清单功能之前创建的链接
+func (s *HistoryStore) LinkOwner(path string) (models.ManifestEntry, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    entry, ok := s.links[path]
+    return entry, ok
+}
+
+// matches 任务是否满足筛选条件
+func (f HistoryFilter) matches(job *models.Job) bool {
+    if f.Visible != nil && !f.Visible(job) {
+        return false
+    }
+    if f.Mode != "" && job.Request.Mode != f.Mode {
+        return false
+    }
+    if f.Status != "" && job.Status != f.Status {
+        return false
+    }
+    if f.User != "" && job.User != f.User {
+        return false
+    }
+    if f.Series != "" {
+        if job.Result == nil || !containsFold(job.Result.SeriesName, f.Series) {
+            return false
+        }
+    }
+    if f.File != "" {
+        if job.Result == nil {
+            return false
+        }
+        for _, op := range job.Result.Operations {
+            if containsFold(op.Source, f.File) || containsFold(op.Target, f.File) {
+                return true
+            }
+        }
+        return false
+    }
+    return true
+}
+
+// containsFold 不区分大小写的包含判断
+func containsFold(s, substr string) bool {
+    return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// Close 关闭历史文件
+func (s *HistoryStore) Close() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.file.Close()
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+    "testing"
+    "time"
+    "vdsymlink-web/models"
+)
+
+func TestHistoryStoreKeepsLimit(t *testing.T) {
+    dir := t.TempDir()
+    store, err := NewHistoryStore(dir, 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    record := func(id, status string) {
+        t.Helper()
+        if err := store.Record(&models.Job{ID: id, Status: status, CreatedAt: time.Now()}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // 未结束的任务不会被删除
+    record("a", models.JobRunning)
+    record("b", models.JobSucceeded)
+    record("c", models.JobSucceeded)
+    for id, want := range map[string]bool{"a": true, "b": false, "c": true} {
+        if _, ok := store.Get(id); ok != want {
+            t.Fatalf("任务 %s 是否保留: %v，期望 %v", id, ok, want)
+        }
+    }
+    record("a", models.JobSucceeded)
+    record("d", models.JobSucceeded)
+    store.Close()
+
+    // 重新打开时只加载保留的任务
+    store, err = NewHistoryStore(dir, 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer store.Close()
+    page := store.Query(HistoryFilter{})
+    if page.Total != 2 || page.Jobs[0].ID != "d" || page.Jobs[1].ID != "c" {
+        t.Fatalf("重新打开后的任务历史: %+v", page.Jobs)
+    }
+}
+
+func TestHistoryStoreLinkOwner(t *testing.T) {
+    store, err := NewHistoryStore(t.TempDir(), 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer store.Close()
+
+    link := func(id string, created time.Time, source string) *models.Job {
+        return &models.Job{
+            ID:        id,
+            Status:    models.JobSucceeded,
+            CreatedAt: created,
+            Result: &models.ProcessResult{Operations: []models.FileOperation{
+                {Action: "link", Source: source, Target: "/lib/Show/S01/Show.S01E01.mkv", Status: models.OperationDone},
+            }},
+        }
+    }
+    older := link("old", time.Now().Add(-time.Hour), "/src/a.mkv")
+    store.Record(older)
+    store.Record(link("new", time.Now(), "/src/b.mkv"))
+    // 旧任务的记录更新不改变链接的创建任务
+    store.Record(older)
+
+    owner, ok := store.LinkOwner("/lib/Show/S01/Show.S01E01.mkv")
+    if !ok || owner.JobID != "new" || owner.Source != "/src/b.mkv" {
+        t.Fatalf("链接的创建任务: %+v, %v", owner, ok)
+    }
+    if _, ok := store.LinkOwner("/lib/Show/S01/Show.S01E02.mkv"); ok {
+        t.Fatal("没有创建记录的链接不应找到创建任务")
+    }
+}
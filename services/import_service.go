@@ -0,0 +1,456 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+    "vdsymlink-web/models"
+)
+
+// 批量导入记录文件名，保存在数据目录中
+const importBatchFile = "imports.json"
+
+// 查找候选文件夹的最大深度（相对下载目录），用于跳过按分类分组的中间目录
+const importMaxDepth = 4
+
+// ImportService 扫描下载目录中可导入的剧集、季和电影文件夹，并将选中的文件夹作为一批任务加入队列
+type ImportService struct {
+    symlink *SymlinkService
+    jobs    *JobManager
+    path    string
+
+    mu      sync.Mutex
+    batches []*models.ImportBatch     // 按时间倒序
+    running map[string]*runningImport // 正在加入队列的批量导入
+}
+
+// runningImport 正在加入队列的批量导入的取消函数和结束通知
+type runningImport struct {
+    cancel context.CancelFunc
+    done   chan struct{}
+}
+
+func NewImportService(symlink *SymlinkService, jobs *JobManager, dataDir string) (*ImportService, error) {
+    s := &ImportService{
+        symlink: symlink,
+        jobs:    jobs,
+        path:    filepath.Join(dataDir, importBatchFile),
+        running: make(map[string]*runningImport),
+    }
+    if err := s.load(); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// PlanImport 列出 root 中的候选文件夹：直接包含视频文件的文件夹（一个视频文件时视为电影），
+// 以及剧集文件夹中包含视频文件的季子文件夹。候选文件夹中的其他子文件夹（如特典）不导入
+func (s *ImportService) PlanImport(ctx context.Context, root, targetDir string) (*models.ImportPlan, error) {
+    info, err := os.Stat(root)
+    if err != nil {
+        return nil, fmt.Errorf("无法访问下载目录: %v", err)
+    }
+    if !info.IsDir() {
+        return nil, fmt.Errorf("路径不是目录: %s", root)
+    }
+    if targetSeasonDirRegex.MatchString(filepath.Base(targetDir)) {
+        return nil, fmt.Errorf("批量导入的目标目录不能是季目录: %s", targetDir)
+    }
+
+    plan := &models.ImportPlan{Root: root, TargetDir: targetDir, Candidates: []models.ImportCandidate{}}
+    entries, err := os.ReadDir(root)
+    if err != nil {
+        return nil, fmt.Errorf("无法读取下载目录: %v", err)
+    }
+    for _, entry := range entries {
+        if entry.IsDir() {
+            if err := s.planFolder(ctx, filepath.Join(root, entry.Name()), targetDir, 1, plan); err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    sort.SliceStable(plan.Candidates, func(i, j int) bool {
+        return plan.Candidates[i].SourceDir < plan.Candidates[j].SourceDir
+    })
+    return plan, nil
+}
+
+// planFolder 判断文件夹是否为候选文件夹，既没有视频文件也没有季子文件夹时继续查找其子文件夹
+func (s *ImportService) planFolder(ctx context.Context, dir, targetDir string, depth int, plan *models.ImportPlan) error {
+    videos, err := s.symlink.getVideoFiles(ctx, dir)
+    if err != nil {
+        if ctx.Err() != nil {
+            return ErrCancelled
+        }
+        // 跳过无法读取的目录
+        return nil
+    }
+    entries, _ := os.ReadDir(dir)
+
+    var seasons, others []string
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            continue
+        }
+        path := filepath.Join(dir, entry.Name())
+        if _, ok := matchPatterns(entry.Name(), precompiledSeasonRegexes); ok {
+            seasons = append(seasons, path)
+        } else {
+            others = append(others, path)
+        }
+    }
+
+    found := false
+    if len(videos) > 0 {
+        plan.Candidates = append(plan.Candidates, s.candidate(dir, targetDir, videos, len(seasons) == 0))
+        found = true
+    }
+    for _, seasonDir := range seasons {
+        seasonVideos, err := s.symlink.getVideoFiles(ctx, seasonDir)
+        if err != nil && ctx.Err() != nil {
+            return ErrCancelled
+        }
+        if len(seasonVideos) > 0 {
+            plan.Candidates = append(plan.Candidates, s.seasonCandidate(seasonDir, targetDir, len(seasonVideos)))
+            found = true
+        }
+    }
+    if found || depth >= importMaxDepth {
+        return nil
+    }
+
+    // 季子文件夹中没有视频文件时也作为普通文件夹查找
+    for _, sub := range append(others, seasons...) {
+        if err := s.planFolder(ctx, sub, targetDir, depth+1, plan); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// candidate 直接包含视频文件的文件夹，按链接模式的规则预计剧集名、季数和目标目录
+func (s *ImportService) candidate(dir, targetDir string, videos []string, allowMovie bool) models.ImportCandidate {
+    item := models.ImportCandidate{
+        SourceDir:  dir,
+        TargetDir:  targetDir,
+        Kind:       models.ImportSeries,
+        SeriesName: filepath.Base(dir),
+        Files:      len(videos),
+    }
+    if allowMovie && len(videos) == 1 {
+        item.Kind = models.ImportMovie
+        item.FinalDir = targetDir
+        item.Exists = pathExists(filepath.Join(targetDir, item.SeriesName+filepath.Ext(videos[0])))
+        return item
+    }
+
+    item.Season = s.symlink.detectSeason(targetDir, videos)
+    if filepath.Base(targetDir) == item.SeriesName {
+        item.FinalDir = filepath.Join(targetDir, s.symlink.naming.SeasonDir(item.Season))
+    } else {
+        item.FinalDir = filepath.Join(targetDir, item.SeriesName, s.symlink.naming.SeasonDir(item.Season))
+    }
+    item.Exists = pathExists(item.FinalDir)
+    return item
+}
+
+// seasonCandidate 季子文件夹，目标目录为 剧集名/季目录，剧集名使用上级文件夹名
+func (s *ImportService) seasonCandidate(dir, targetDir string, files int) models.ImportCandidate {
+    season, _ := matchPatterns(filepath.Base(dir), precompiledSeasonRegexes)
+    season = formatNumber(season)
+    series := filepath.Base(filepath.Dir(dir))
+    final := filepath.Join(targetDir, series, s.symlink.naming.SeasonDir(season))
+    return models.ImportCandidate{
+        SourceDir:  dir,
+        TargetDir:  final,
+        Kind:       models.ImportSeason,
+        SeriesName: series,
+        Season:     season,
+        Files:      files,
+        FinalDir:   final,
+        Exists:     pathExists(final),
+    }
+}
+
+// Start 按计划为选中的文件夹创建一批任务，在后台依次加入任务队列（队列已满时等待）
+func (s *ImportService) Start(plan *models.ImportPlan, req models.ImportRequest, user *User) (*models.ImportBatch, error) {
+    selected := make(map[string]bool)
+    for _, source := range req.Sources {
+        selected[filepath.Clean(source)] = true
+    }
+
+    batch := &models.ImportBatch{
+        ID:        NewJobID(),
+        Root:      plan.Root,
+        TargetDir: plan.TargetDir,
+        Mode:      req.Mode,
+        CreatedAt: time.Now(),
+    }
+    if user != nil {
+        batch.User = user.Username
+    }
+    for _, candidate := range plan.Candidates {
+        if (len(selected) == 0 && !candidate.Exists) || selected[candidate.SourceDir] {
+            batch.Items = append(batch.Items, models.ImportItem{ImportCandidate: candidate})
+            delete(selected, candidate.SourceDir)
+        }
+    }
+    for source := range selected {
+        return nil, fmt.Errorf("不是可导入的文件夹: %s", source)
+    }
+    if len(batch.Items) == 0 {
+        return nil, fmt.Errorf("没有需要导入的文件夹")
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    running := &runningImport{cancel: cancel, done: make(chan struct{})}
+    s.mu.Lock()
+    s.batches = append([]*models.ImportBatch{batch}, s.batches...)
+    s.running[batch.ID] = running
+    err := s.save()
+    s.mu.Unlock()
+    if err != nil {
+        fmt.Printf("⚠️ 无法保存批量导入记录: %v\n", err)
+    }
+
+    fmt.Printf("📥 批量导入 %s: %s -> %s，共 %d 个文件夹\n", batch.ID, batch.Root, batch.TargetDir, len(batch.Items))
+    go func() {
+        defer func() {
+            s.mu.Lock()
+            delete(s.running, batch.ID)
+            s.mu.Unlock()
+            cancel()
+            close(running.done)
+        }()
+        s.run(ctx, batch.ID, append([]models.ImportItem{}, batch.Items...), req, user)
+    }()
+
+    copied := *batch
+    copied.Items = append([]models.ImportItem{}, batch.Items...)
+    return &copied, nil
+}
+
+// run 将批量导入的文件夹依次加入任务队列，记录每个文件夹的任务ID，ctx 取消时停止
+func (s *ImportService) run(ctx context.Context, id string, items []models.ImportItem, req models.ImportRequest, user *User) {
+    for i, item := range items {
+        if ctx.Err() != nil {
+            return
+        }
+        jobReq := models.ProcessRequest{
+            SourceDir:   item.SourceDir,
+            TargetDir:   item.TargetDir,
+            Mode:        req.Mode,
+            PathMap:     req.PathMap,
+            StrictLinks: req.StrictLinks,
+            Sync:        req.Sync,
+            Async:       true,
+        }
+        opts := ProcessOptions{
+            SourceDir:   item.SourceDir,
+            TargetDir:   item.TargetDir,
+            Mode:        req.Mode,
+            PathMap:     req.PathMap,
+            StrictLinks: req.StrictLinks,
+            Sync:        req.Sync,
+        }
+        job, err := s.jobs.EnqueueWait(ctx, jobReq, user, opts)
+        if errors.Is(err, ErrCancelled) {
+            return
+        }
+
+        s.mu.Lock()
+        if batch := s.find(id); batch != nil {
+            if err != nil {
+                batch.Items[i].Error = err.Error()
+            } else {
+                batch.Items[i].JobID = job.ID
+            }
+            if err := s.save(); err != nil {
+                fmt.Printf("⚠️ 无法保存批量导入记录: %v\n", err)
+            }
+        }
+        s.mu.Unlock()
+    }
+}
+
+// Cancel 取消批量导入：停止加入新的任务，尚未加入队列的文件夹标记为已取消，
+// 已加入队列且未结束的任务按任务取消的规则取消。批量导入不存在时返回 false
+func (s *ImportService) Cancel(id string) (*models.ImportReport, bool) {
+    s.mu.Lock()
+    running := s.running[id]
+    s.mu.Unlock()
+    if running != nil {
+        running.cancel()
+        <-running.done
+    }
+
+    s.mu.Lock()
+    batch := s.find(id)
+    if batch == nil {
+        s.mu.Unlock()
+        return nil, false
+    }
+    var jobIDs []string
+    for i := range batch.Items {
+        item := &batch.Items[i]
+        switch {
+        case item.JobID != "":
+            jobIDs = append(jobIDs, item.JobID)
+        case item.Error == "":
+            item.Cancelled = true
+        }
+    }
+    if err := s.save(); err != nil {
+        fmt.Printf("⚠️ 无法保存批量导入记录: %v\n", err)
+    }
+    s.mu.Unlock()
+
+    for _, jobID := range jobIDs {
+        if job, ok := s.jobs.Get(jobID); ok && !job.Finished() {
+            s.jobs.Cancel(jobID)
+        }
+    }
+    fmt.Printf("⏹️ 已取消批量导入 %s\n", id)
+    return s.Report(id)
+}
+
+// Report 汇总批量导入中每个任务的状态和处理结果
+func (s *ImportService) Report(id string) (*models.ImportReport, bool) {
+    s.mu.Lock()
+    batch := s.find(id)
+    if batch == nil {
+        s.mu.Unlock()
+        return nil, false
+    }
+    items := append([]models.ImportItem{}, batch.Items...)
+    s.mu.Unlock()
+
+    report := &models.ImportReport{
+        ID:        batch.ID,
+        Root:      batch.Root,
+        TargetDir: batch.TargetDir,
+        Mode:      batch.Mode,
+        User:      batch.User,
+        CreatedAt: batch.CreatedAt,
+        Total:     len(items),
+        Items:     make([]models.ImportItemReport, 0, len(items)),
+    }
+    for _, item := range items {
+        itemReport := models.ImportItemReport{ImportItem: item, Status: "pending"}
+        switch {
+        case item.Cancelled:
+            itemReport.Status = models.JobCancelled
+        case item.Error != "":
+            itemReport.Status = models.JobFailed
+        case item.JobID != "":
+            if job, ok := s.jobs.Get(item.JobID); ok {
+                itemReport.Status = job.Status
+                if job.Error != "" {
+                    itemReport.Error = job.Error
+                }
+                if job.Result != nil {
+                    itemReport.Processed = job.Result.Processed
+                    itemReport.Failed = job.Result.Failed
+                }
+            } else {
+                itemReport.Status = models.JobFailed
+                itemReport.Error = "任务不存在或已过期"
+            }
+        }
+
+        switch itemReport.Status {
+        case models.JobSucceeded:
+            report.Succeeded++
+        case models.JobFailed:
+            report.Failed++
+        case models.JobCancelled:
+            report.Cancelled++
+        default:
+            report.Pending++
+        }
+        report.Processed += itemReport.Processed
+        report.FailedFiles += itemReport.Failed
+        report.Items = append(report.Items, itemReport)
+    }
+    report.Done = report.Pending == 0
+    return report, true
+}
+
+// Reports 最近的批量导入报告
+func (s *ImportService) Reports(limit int) []*models.ImportReport {
+    s.mu.Lock()
+    var ids []string
+    for _, batch := range s.batches {
+        if limit > 0 && len(ids) >= limit {
+            break
+        }
+        ids = append(ids, batch.ID)
+    }
+    s.mu.Unlock()
+
+    reports := []*models.ImportReport{}
+    for _, id := range ids {
+        if report, ok := s.Report(id); ok {
+            reports = append(reports, report)
+        }
+    }
+    return reports
+}
+
+// find 调用方需持有 s.mu
+func (s *ImportService) find(id string) *models.ImportBatch {
+    for _, batch := range s.batches {
+        if batch.ID == id {
+            return batch
+        }
+    }
+    return nil
+}
+
+// load 读取批量导入记录，服务停止时尚未加入队列的文件夹标记为未执行
+func (s *ImportService) load() error {
+    data, err := os.ReadFile(s.path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("无法读取批量导入记录: %v", err)
+    }
+    if err := json.Unmarshal(data, &s.batches); err != nil {
+        return fmt.Errorf("批量导入记录文件格式错误: %v", err)
+    }
+    for _, batch := range s.batches {
+        for i := range batch.Items {
+            if batch.Items[i].JobID == "" && batch.Items[i].Error == "" {
+                batch.Items[i].Error = "服务重启，未执行"
+            }
+        }
+    }
+    return nil
+}
+
+// save 保存批量导入记录，先写临时文件再重命名，调用方需持有 s.mu
+func (s *ImportService) save() error {
+    data, err := json.MarshalIndent(s.batches, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := s.path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, s.path)
+}
+
+// pathExists 路径是否存在（不跟随符号链接）
+func pathExists(path string) bool {
+    _, err := os.Lstat(path)
+    return err == nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+    "sync"
+    "vdsymlink-web/models"
+)
+
+// 每个订阅者的事件缓冲区大小，缓冲区满时丢弃事件
+const eventBufferSize = 1024
+
+// jobEventHub 向订阅者分发任务事件
+type jobEventHub struct {
+    mu          sync.Mutex
+    subscribers map[string]map[chan models.JobEvent]struct{}
+    history     map[string][]models.JobEvent // 运行中任务的日志，供后加入的订阅者回放
+}
+
+func newJobEventHub() *jobEventHub {
+    return &jobEventHub{
+        subscribers: make(map[string]map[chan models.JobEvent]struct{}),
+        history:     make(map[string][]models.JobEvent),
+    }
+}
+
+// subscribe 订阅任务事件，返回事件通道、已产生的日志和取消订阅函数
+func (h *jobEventHub) subscribe(id string) (<-chan models.JobEvent, []models.JobEvent, func()) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    ch := make(chan models.JobEvent, eventBufferSize)
+    if h.subscribers[id] == nil {
+        h.subscribers[id] = make(map[chan models.JobEvent]struct{})
+    }
+    h.subscribers[id][ch] = struct{}{}
+    replay := append([]models.JobEvent(nil), h.history[id]...)
+
+    cancel := func() {
+        h.mu.Lock()
+        defer h.mu.Unlock()
+        if subs, ok := h.subscribers[id]; ok {
+            if _, ok := subs[ch]; ok {
+                delete(subs, ch)
+                close(ch)
+            }
+            if len(subs) == 0 {
+                delete(h.subscribers, id)
+            }
+        }
+    }
+    return ch, replay, cancel
+}
+
+// publish 推送事件
+func (h *jobEventHub) publish(id string, event models.JobEvent) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    if event.Type == models.EventLog {
+        h.history[id] = append(h.history[id], event)
+    }
+    for ch := range h.subscribers[id] {
+        select {
+        case ch <- event:
+        default:
+        }
+    }
+}
+
+// close 推送最终事件并关闭所有订阅
+func (h *jobEventHub) close(id string, event models.JobEvent) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    for ch := range h.subscribers[id] {
+        select {
+        case ch <- event:
+        default:
+        }
+        close(ch)
+    }
+    delete(h.subscribers, id)
+    delete(h.history, id)
+}
@@ -0,0 +1,465 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "path/filepath"
+    "sync"
+    "time"
+    "vdsymlink-web/models"
+)
+
+// jobRunner 执行任务的函数
+type jobRunner func(ctx context.Context, onEvent EventFunc) (*models.ProcessResult, error)
+
+// cancelWait 取消运行中的任务后等待其停止的最长时间
+const cancelWait = 30 * time.Second
+
+// checkpointOps 任务运行中每完成多少个文件操作将已完成的操作写入任务历史，
+// 服务在任务中途停止时（如迁移大量文件）重启后仍可查看和撤销已完成的操作
+const checkpointOps = 20
+
+// runningJob 运行中任务的取消函数和结束通知
+type runningJob struct {
+    cancel context.CancelFunc
+    done   chan struct{}
+}
+
+type queuedJob struct {
+    id    string
+    paths []string
+    run   jobRunner
+}
+
+// JobManager 管理任务的执行：同步执行或加入有界的工作池异步执行
+type JobManager struct {
+    service  *SymlinkService
+    store    *JobStore
+    history  *HistoryStore
+    queue    chan queuedJob
+    events   *jobEventHub
+    locks    *PathLocks
+    notifier *MediaNotifier
+    webhooks *WebhookService
+    library  *LibraryService // 任务结束后使引用索引失效
+
+    mu      sync.Mutex
+    running map[string]*runningJob
+}
+
+func NewJobManager(service *SymlinkService, store *JobStore, history *HistoryStore, workers, queueSize int) *JobManager {
+    if workers <= 0 {
+        workers = 1
+    }
+    if queueSize <= 0 {
+        queueSize = 100
+    }
+
+    m := &JobManager{
+        service: service,
+        store:   store,
+        history: history,
+        queue:   make(chan queuedJob, queueSize),
+        events:  newJobEventHub(),
+        locks:   NewPathLocks(),
+        running: make(map[string]*runningJob),
+    }
+    for i := 0; i < workers; i++ {
+        go m.worker()
+    }
+    return m
+}
+
+// SetNotifier 设置任务成功后通知刷新的媒体服务器
+func (m *JobManager) SetNotifier(notifier *MediaNotifier) {
+    m.notifier = notifier
+}
+
+// SetWebhooks 设置任务事件的通知
+func (m *JobManager) SetWebhooks(webhooks *WebhookService) {
+    m.webhooks = webhooks
+}
+
+// SetLibrary 设置任务结束后需要丢弃引用索引缓存的媒体库服务
+func (m *JobManager) SetLibrary(library *LibraryService) {
+    m.library = library
+}
+
+// worker 从队列中取出任务执行
+func (m *JobManager) worker() {
+    for queued := range m.queue {
+        // 跳过排队期间已取消的任务
+        if job, ok := m.store.Get(queued.id); !ok || job.Status != models.JobQueued {
+            continue
+        }
+        m.execute(context.Background(), queued.id, queued.paths, queued.run)
+    }
+}
+
+// Run 同步执行处理任务，ctx 取消（如客户端断开连接）时任务在两个文件之间停止
+// 源目录或目标目录正被其他任务使用时不等待，直接返回 *BusyError
+func (m *JobManager) Run(ctx context.Context, req models.ProcessRequest, user *User, opts ProcessOptions) (*models.Job, error) {
+    job := m.create(req, user)
+    paths := opts.lockPaths()
+    if err := m.locks.TryLock(job.ID, paths); err != nil {
+        m.store.Delete(job.ID)
+        return nil, err
+    }
+
+    m.execute(ctx, job.ID, paths, m.processRunner(job.ID, opts))
+    finished, _ := m.store.Get(job.ID)
+    return finished, nil
+}
+
+// RunWait 同步执行处理任务，源目录或目标目录正被其他任务使用时等待其结束，用于批量请求
+func (m *JobManager) RunWait(ctx context.Context, req models.ProcessRequest, user *User, opts ProcessOptions) *models.Job {
+    job := m.create(req, user)
+    m.execute(ctx, job.ID, opts.lockPaths(), m.processRunner(job.ID, opts))
+    finished, _ := m.store.Get(job.ID)
+    return finished
+}
+
+// Enqueue 将处理任务加入队列，立即返回排队中的任务
+// 执行时源目录或目标目录正被其他任务使用则等待其结束
+func (m *JobManager) Enqueue(req models.ProcessRequest, user *User, opts ProcessOptions) (*models.Job, error) {
+    job := m.create(req, user)
+
+    select {
+    case m.queue <- queuedJob{id: job.ID, paths: opts.lockPaths(), run: m.processRunner(job.ID, opts)}:
+        m.persist(job.ID)
+        return job, nil
+    default:
+        m.finish(job.ID, nil, fmt.Errorf("任务队列已满，请稍后重试"))
+        return nil, fmt.Errorf("任务队列已满，请稍后重试")
+    }
+}
+
+// EnqueueWait 与 Enqueue 相同，但队列已满时等待空位，用于批量提交大量任务
+func (m *JobManager) EnqueueWait(ctx context.Context, req models.ProcessRequest, user *User, opts ProcessOptions) (*models.Job, error) {
+    job := m.create(req, user)
+
+    select {
+    case m.queue <- queuedJob{id: job.ID, paths: opts.lockPaths(), run: m.processRunner(job.ID, opts)}:
+        m.persist(job.ID)
+        return job, nil
+    case <-ctx.Done():
+        m.finish(job.ID, nil, ErrCancelled)
+        return nil, ErrCancelled
+    }
+}
+
+// Undo 同步撤销任务，结果保存为新任务
+func (m *JobManager) Undo(ctx context.Context, original *models.Job, user *User) (*models.Job, error) {
+    job := m.create(original.Request, user)
+    job.UndoOf = original.ID
+
+    // 重启后原任务只存在于任务历史中
+    if _, ok := m.store.Get(original.ID); !ok {
+        copied := *original
+        m.store.Save(&copied)
+    }
+
+    // 标记原任务已撤销，避免重复撤销
+    reserved := false
+    m.store.Update(original.ID, func(o *models.Job) {
+        if o.Result != nil && o.UndoOf == "" && o.UndoneBy == "" {
+            o.UndoneBy = job.ID
+            reserved = true
+        }
+    })
+    if !reserved {
+        m.store.Delete(job.ID)
+        return nil, fmt.Errorf("任务 %s 无法撤销或已被撤销", original.ID)
+    }
+    m.store.Save(job)
+
+    paths := undoPaths(original.Result)
+    if err := m.locks.TryLock(job.ID, paths); err != nil {
+        m.store.Update(original.ID, func(o *models.Job) {
+            o.UndoneBy = ""
+        })
+        m.store.Delete(job.ID)
+        return nil, err
+    }
+    m.persist(original.ID)
+
+    m.execute(ctx, job.ID, paths, func(ctx context.Context, onEvent EventFunc) (*models.ProcessResult, error) {
+        return m.service.Undo(ctx, original.ID, original.Result, onEvent)
+    })
+    finished, _ := m.store.Get(job.ID)
+    return finished, nil
+}
+
+// Get 获取任务，内存中没有时从任务历史中查找
+func (m *JobManager) Get(id string) (*models.Job, bool) {
+    if job, ok := m.store.Get(id); ok {
+        return job, true
+    }
+    return m.history.Get(id)
+}
+
+// History 分页查询任务历史
+func (m *JobManager) History(filter HistoryFilter) models.HistoryPage {
+    return m.history.Query(filter)
+}
+
+// Subscribe 订阅任务事件，返回事件通道、已产生的日志和取消订阅函数
+// 任务结束时推送 status 事件并关闭通道
+func (m *JobManager) Subscribe(id string) (<-chan models.JobEvent, []models.JobEvent, func()) {
+    return m.events.subscribe(id)
+}
+
+// Cancel 取消任务：排队中的任务直接取消；运行中的任务在当前文件处理完成（或复制中断）后停止，
+// 等待任务停止后返回，结果中记录了已完成和未执行的文件操作
+func (m *JobManager) Cancel(id string) (*models.Job, error) {
+    var cancelErr error
+    cancelled := false
+    found := m.store.Update(id, func(job *models.Job) {
+        switch job.Status {
+        case models.JobQueued:
+            job.Status = models.JobCancelled
+            job.FinishedAt = time.Now()
+            cancelled = true
+        case models.JobRunning, models.JobWaiting:
+        default:
+            cancelErr = fmt.Errorf("任务已结束")
+        }
+    })
+    if !found {
+        return nil, fmt.Errorf("任务不存在或已过期")
+    }
+    if cancelErr != nil {
+        job, _ := m.store.Get(id)
+        return job, cancelErr
+    }
+
+    if cancelled {
+        m.persist(id)
+        job, _ := m.store.Get(id)
+        m.events.close(id, models.JobEvent{Type: models.EventStatus, Job: job})
+        return job, nil
+    }
+
+    m.mu.Lock()
+    running, ok := m.running[id]
+    m.mu.Unlock()
+    if ok {
+        running.cancel()
+        select {
+        case <-running.done:
+        case <-time.After(cancelWait):
+        }
+    }
+    job, _ := m.store.Get(id)
+    return job, nil
+}
+
+// Exclusive 锁定目录后执行不作为任务记录的修改（如修复媒体库链接），目录正被任务使用时返回 *BusyError，
+// 执行期间需要这些目录的任务等待其结束
+func (m *JobManager) Exclusive(name string, paths []string, fn func() error) error {
+    owner := name + "-" + NewJobID()
+    if err := m.locks.TryLock(owner, paths); err != nil {
+        return err
+    }
+    defer m.locks.Unlock(owner)
+    return fn()
+}
+
+// List 按创建时间倒序列出任务，visible 为 nil 时不按用户过滤
+func (m *JobManager) List(status string, limit int, visible func(job *models.Job) bool) []*models.Job {
+    return m.store.List(status, limit, visible)
+}
+
+// processRunner 创建执行文件处理的函数
+func (m *JobManager) processRunner(jobID string, opts ProcessOptions) jobRunner {
+    opts.JobID = jobID
+    return func(ctx context.Context, onEvent EventFunc) (*models.ProcessResult, error) {
+        opts.OnEvent = onEvent
+        return m.service.ProcessFiles(ctx, opts)
+    }
+}
+
+// create 创建并保存排队中的任务
+func (m *JobManager) create(req models.ProcessRequest, user *User) *models.Job {
+    job := &models.Job{
+        ID:        NewJobID(),
+        Request:   req,
+        Status:    models.JobQueued,
+        CreatedAt: time.Now(),
+    }
+    if user != nil {
+        job.User = user.Username
+    }
+    m.store.Save(job)
+
+    copied := *job
+    return &copied
+}
+
+// execute 锁定任务使用的目录后执行任务，更新状态和进度并推送事件
+func (m *JobManager) execute(ctx context.Context, id string, paths []string, run jobRunner) {
+    ctx, cancel := context.WithCancel(ctx)
+    running := &runningJob{cancel: cancel, done: make(chan struct{})}
+    m.mu.Lock()
+    m.running[id] = running
+    m.mu.Unlock()
+    defer func() {
+        m.mu.Lock()
+        delete(m.running, id)
+        m.mu.Unlock()
+        cancel()
+        m.locks.Unlock(id)
+        close(running.done)
+    }()
+
+    err := m.locks.Lock(ctx, id, paths, func(busy *BusyError) {
+        m.store.Update(id, func(job *models.Job) {
+            job.Status = models.JobWaiting
+            job.WaitingFor = busy.JobID
+        })
+        if job, ok := m.store.Get(id); ok {
+            m.events.publish(id, models.JobEvent{Type: models.EventStatus, Job: job})
+        }
+        m.events.publish(id, models.JobEvent{Type: models.EventLog, Message: busy.Error() + "\n"})
+    })
+    if err != nil {
+        m.finish(id, nil, err)
+        return
+    }
+
+    m.store.Update(id, func(job *models.Job) {
+        job.WaitingFor = ""
+        job.Status = models.JobRunning
+        job.StartedAt = time.Now()
+    })
+    m.persist(id)
+    if job, ok := m.store.Get(id); ok {
+        m.events.publish(id, models.JobEvent{Type: models.EventStatus, Job: job})
+        m.dispatch(job)
+    }
+
+    var done []models.FileOperation
+    result, err := run(ctx, func(event models.JobEvent) {
+        switch event.Type {
+        case models.EventProgress:
+            m.store.Update(id, func(job *models.Job) {
+                job.Progress = *event.Progress
+            })
+        case models.EventOperation:
+            if event.Operation.Status == models.OperationDone {
+                done = append(done, *event.Operation)
+                if len(done)%checkpointOps == 0 {
+                    m.checkpoint(id, done)
+                }
+            }
+        }
+        m.events.publish(id, event)
+    })
+
+    m.finish(id, result, err)
+}
+
+// finish 记录任务结果并通知订阅者
+func (m *JobManager) finish(id string, result *models.ProcessResult, err error) {
+    m.store.Update(id, func(job *models.Job) {
+        job.FinishedAt = time.Now()
+        job.Result = result
+        switch {
+        case errors.Is(err, ErrCancelled):
+            job.Status = models.JobCancelled
+            job.Error = err.Error()
+        case err != nil:
+            job.Status = models.JobFailed
+            job.Error = err.Error()
+        default:
+            job.Status = models.JobSucceeded
+        }
+    })
+
+    m.persist(id)
+    if m.library != nil {
+        m.library.InvalidateReferences()
+    }
+    job, ok := m.store.Get(id)
+    if !ok {
+        return
+    }
+    m.events.close(id, models.JobEvent{Type: models.EventStatus, Job: job})
+    m.dispatch(job)
+
+    if m.notifier.Enabled() && job.Status == models.JobSucceeded && job.Result != nil && job.Result.Processed+job.Result.Removed > 0 {
+        go m.notify(id, job.Result.TargetDir)
+    }
+}
+
+// dispatch 在后台发送任务事件通知，同一任务的事件按发生顺序发送
+func (m *JobManager) dispatch(job *models.Job) {
+    if !m.webhooks.Enabled() {
+        return
+    }
+    if event := webhookEvent(job); event != "" {
+        m.webhooks.Enqueue(event, job)
+    }
+}
+
+// notify 通知媒体服务器刷新目标目录，并将结果记录到任务上
+func (m *JobManager) notify(id, path string) {
+    results := m.notifier.Refresh(context.Background(), path)
+    if m.store.Update(id, func(job *models.Job) {
+        job.Notifications = results
+    }) {
+        m.persist(id)
+        return
+    }
+
+    // 任务已不在内存中时直接更新任务历史
+    if job, ok := m.history.Get(id); ok {
+        job.Notifications = results
+        if err := m.history.Record(job); err != nil {
+            fmt.Printf("⚠️ 保存任务历史失败: %v\n", err)
+        }
+    }
+}
+
+// checkpoint 将运行中任务已完成的文件操作写入任务历史，内存中的任务在结束前不包含结果，避免被撤销
+func (m *JobManager) checkpoint(id string, done []models.FileOperation) {
+    job, ok := m.store.Get(id)
+    if !ok {
+        return
+    }
+    job.Result = &models.ProcessResult{
+        TargetDir:  job.Request.TargetDir,
+        Operations: append([]models.FileOperation(nil), done...),
+    }
+    if err := m.history.Record(job); err != nil {
+        fmt.Printf("⚠️ 保存任务历史失败: %v\n", err)
+    }
+}
+
+// persist 将任务的当前状态写入任务历史
+func (m *JobManager) persist(id string) {
+    job, ok := m.store.Get(id)
+    if !ok {
+        return
+    }
+    if err := m.history.Record(job); err != nil {
+        fmt.Printf("⚠️ 保存任务历史失败: %v\n", err)
+    }
+}
+
+// lockPaths 任务执行期间需要锁定的目录
+func (opts ProcessOptions) lockPaths() []string {
+    return []string{opts.SourceDir, opts.TargetDir}
+}
+
+// undoPaths 撤销任务需要锁定的目录：原任务的目标目录和被移动文件的原目录
+func undoPaths(result *models.ProcessResult) []string {
+    paths := []string{result.TargetDir}
+    for _, op := range result.Operations {
+        if op.Status == models.OperationDone {
+            paths = append(paths, filepath.Dir(op.Source))
+        }
+    }
+    return paths
+}
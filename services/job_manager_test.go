@@ -0,0 +1,104 @@
+package services
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "vdsymlink-web/models"
+)
+
+// runJob 同步执行处理任务
+func runJob(t *testing.T, env *testEnv, mode, sourceDir, targetDir string) *models.Job {
+    t.Helper()
+    req := models.ProcessRequest{SourceDir: sourceDir, TargetDir: targetDir, Mode: mode}
+    opts := ProcessOptions{SourceDir: sourceDir, TargetDir: targetDir, Mode: mode}
+    job, err := env.jobs.Run(context.Background(), req, nil, opts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if job.Status != models.JobSucceeded {
+        t.Fatalf("任务状态 %s: %s", job.Status, job.Error)
+    }
+    return job
+}
+
+// doneOperations 任务中成功的文件操作
+func doneOperations(t *testing.T, job *models.Job) []models.FileOperation {
+    t.Helper()
+    var ops []models.FileOperation
+    for _, op := range job.Result.Operations {
+        if op.Status == models.OperationDone {
+            ops = append(ops, op)
+        }
+    }
+    if len(ops) == 0 {
+        t.Fatalf("任务没有成功的文件操作: %s", job.Result.Log)
+    }
+    return ops
+}
+
+func undoJob(t *testing.T, env *testEnv, job *models.Job) {
+    t.Helper()
+    undo, err := env.jobs.Undo(context.Background(), job, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if undo.Status != models.JobSucceeded || undo.Result.Failed > 0 {
+        t.Fatalf("撤销失败 %s: %s\n%s", undo.Status, undo.Error, undo.Result.Log)
+    }
+    if _, err := env.jobs.Undo(context.Background(), job, nil); err == nil {
+        t.Fatal("已撤销的任务不应再次撤销")
+    }
+}
+
+func TestUndoLinkJob(t *testing.T) {
+    env := newTestEnv(t)
+    show := filepath.Join(env.source, "Show")
+    touch(t, filepath.Join(show, "Show.E01.mkv"), filepath.Join(show, "Show.E02.mkv"))
+
+    job := runJob(t, env, "link", show, env.target)
+    ops := doneOperations(t, job)
+    for _, op := range ops {
+        if info, err := os.Lstat(op.Target); err != nil || info.Mode()&os.ModeSymlink == 0 {
+            t.Fatalf("应创建符号链接 %s: %v", op.Target, err)
+        }
+    }
+
+    undoJob(t, env, job)
+    for _, op := range ops {
+        if _, err := os.Lstat(op.Target); !os.IsNotExist(err) {
+            t.Errorf("撤销后链接应被删除: %s", op.Target)
+        }
+        if _, err := os.Stat(op.Source); err != nil {
+            t.Errorf("撤销不应影响源文件 %s: %v", op.Source, err)
+        }
+    }
+}
+
+func TestUndoMoveJob(t *testing.T) {
+    env := newTestEnv(t)
+    show := filepath.Join(env.source, "Show")
+    touch(t, filepath.Join(show, "Show.E01.mkv"), filepath.Join(show, "Show.E02.mkv"))
+
+    job := runJob(t, env, "move", show, env.target)
+    ops := doneOperations(t, job)
+    for _, op := range ops {
+        if _, err := os.Lstat(op.Source); !os.IsNotExist(err) {
+            t.Fatalf("移动后源文件应不存在: %s", op.Source)
+        }
+        if data, err := os.ReadFile(op.Target); err != nil || string(data) != filepath.Base(op.Source) {
+            t.Fatalf("移动后的文件内容不正确 %s: %v", op.Target, err)
+        }
+    }
+
+    undoJob(t, env, job)
+    for _, op := range ops {
+        if data, err := os.ReadFile(op.Source); err != nil || string(data) != filepath.Base(op.Source) {
+            t.Errorf("撤销后文件应恢复到原位置 %s: %v", op.Source, err)
+        }
+        if _, err := os.Lstat(op.Target); !os.IsNotExist(err) {
+            t.Errorf("撤销后目标文件应不存在: %s", op.Target)
+        }
+    }
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+    "sync"
+    "vdsymlink-web/models"
+)
+
+// 内存中保留的任务数量上限
+const maxStoredJobs = 500
+
+// JobStore 在服务端保存任务结果
+type JobStore struct {
+    mu    sync.RWMutex
+    jobs  map[string]*models.Job
+    order []string
+}
+
+func NewJobStore() *JobStore {
+    return &JobStore{
+        jobs: make(map[string]*models.Job),
+    }
+}
+
+// NewJobID 生成任务ID
+func NewJobID() string {
+    id, err := randomToken()
+    if err != nil {
+        panic(err)
+    }
+    return id[:16]
+}
+
+// Save 保存任务，超出上限时丢弃最早的任务
+func (s *JobStore) Save(job *models.Job) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, exists := s.jobs[job.ID]; !exists {
+        s.order = append(s.order, job.ID)
+    }
+    s.jobs[job.ID] = job
+
+    for len(s.order) > maxStoredJobs {
+        delete(s.jobs, s.order[0])
+        s.order = s.order[1:]
+    }
+}
+
+// Get 获取任务的副本
+func (s *JobStore) Get(id string) (*models.Job, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    job, ok := s.jobs[id]
+    if !ok {
+        return nil, false
+    }
+    copied := *job
+    return &copied, true
+}
+
+// Update 在锁内修改任务
+func (s *JobStore) Update(id string, fn func(job *models.Job)) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    job, ok := s.jobs[id]
+    if ok {
+        fn(job)
+    }
+    return ok
+}
+
+// Delete 删除任务
+func (s *JobStore) Delete(id string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    delete(s.jobs, id)
+    for i, existing := range s.order {
+        if existing == id {
+            s.order = append(s.order[:i], s.order[i+1:]...)
+            break
+        }
+    }
+}
+
+// List 按创建时间倒序列出任务副本，status 为空时不过滤，limit 小于等于0时不限制数量，visible 为 nil 时不按用户过滤
+func (s *JobStore) List(status string, limit int, visible func(job *models.Job) bool) []*models.Job {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    jobs := []*models.Job{}
+    for i := len(s.order) - 1; i >= 0; i-- {
+        job := s.jobs[s.order[i]]
+        if status != "" && job.Status != status {
+            continue
+        }
+        if visible != nil && !visible(job) {
+            continue
+        }
+        copied := *job
+        jobs = append(jobs, &copied)
+        if limit > 0 && len(jobs) >= limit {
+            break
+        }
+    }
+    return jobs
+}
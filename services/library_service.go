@@ -0,0 +1,344 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "vdsymlink-web/models"
+)
+
+// 修复链接时使用的临时链接后缀，创建成功后替换原链接
+const repairSuffix = ".vdrepair"
+
+// LibraryService 扫描目标媒体库中的断开链接，并删除或重新指向移动后的源文件
+type LibraryService struct {
+    mapper     *PathMapper
+    history    *HistoryStore
+    manifest   *ManifestStore  // 修复前确认链接由本工具创建
+    references *referenceCache // 媒体库目录的引用索引
+}
+
+func NewLibraryService(mapper *PathMapper, history *HistoryStore, manifest *ManifestStore) *LibraryService {
+    return &LibraryService{
+        mapper:     mapper,
+        history:    history,
+        manifest:   manifest,
+        references: newReferenceCache(),
+    }
+}
+
+// Scan 扫描目录中的断开链接，按剧集和季分组，并在 searchDirs 中按文件名和大小查找移动后的源文件
+// root 和 searchDirs 需已解析
+func (s *LibraryService) Scan(ctx context.Context, root string, searchDirs []string) (*models.LibraryScan, error) {
+    info, err := os.Stat(root)
+    if err != nil {
+        return nil, fmt.Errorf("无法访问目录: %v", err)
+    }
+    if !info.IsDir() {
+        return nil, fmt.Errorf("路径不是目录: %s", root)
+    }
+
+    scan := &models.LibraryScan{
+        Root:       root,
+        SearchDirs: append([]string{}, searchDirs...),
+        Groups:     []models.LibraryGroup{},
+    }
+    var broken []models.BrokenLink
+    err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if ctx.Err() != nil {
+            return ErrCancelled
+        }
+        // 跳过无法读取的目录
+        if err != nil || d.Type()&fs.ModeSymlink == 0 {
+            return nil
+        }
+        scan.Links++
+        if link, ok := s.checkLink(path); !ok {
+            broken = append(broken, link)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    if len(broken) > 0 {
+        index, err := indexSourceFiles(ctx, searchDirs)
+        if err != nil {
+            return nil, err
+        }
+        sizes := s.history.LinkSizes()
+        for i := range broken {
+            broken[i].Size = sizes[broken[i].Path]
+            if owner, ok := s.manifest.Owner(broken[i].Path, broken[i].LinkTarget); ok {
+                broken[i].Owner = owner.JobID
+            }
+            broken[i].Candidates = index.find(broken[i])
+        }
+    }
+    scan.Broken = len(broken)
+    scan.Groups = groupBrokenLinks(root, broken)
+    return scan, nil
+}
+
+// checkLink 检查链接目标能否直接或通过任一路径映射反向转换后在本机找到，返回链接信息和是否有效
+func (s *LibraryService) checkLink(path string) (models.BrokenLink, bool) {
+    target, err := os.Readlink(path)
+    if err != nil {
+        return models.BrokenLink{Path: path}, false
+    }
+    local := target
+    if !filepath.IsAbs(local) {
+        local = filepath.Join(filepath.Dir(path), local)
+    }
+    link := models.BrokenLink{Path: path, LinkTarget: target, LocalTarget: local}
+    if _, err := os.Stat(local); err == nil {
+        return link, true
+    }
+
+    for _, name := range s.mapper.Names() {
+        mapped, ok := s.mapper.Mapping(name).Reverse(local)
+        if !ok {
+            continue
+        }
+        if _, err := os.Stat(mapped); err == nil {
+            return link, true
+        }
+        // 记录第一个匹配的映射，重新指向时使用同一映射生成链接目标
+        if link.PathMap == "" {
+            link.PathMap, link.LocalTarget = name, mapped
+        }
+    }
+    return link, false
+}
+
+// Repair 删除或重新指向 root 中的断开链接，req.Links 为空时处理所有断链
+// 用户指定的新源文件需位于 guard 的源目录范围内，不是本工具创建的链接需指定 req.Force
+func (s *LibraryService) Repair(ctx context.Context, req models.RepairRequest, root string, searchDirs []string, guard *PathGuard) ([]models.RepairResult, error) {
+    if req.Action != models.RepairDelete && req.Action != models.RepairRepoint {
+        return nil, fmt.Errorf("不支持的修复操作: %s", req.Action)
+    }
+    scan, err := s.Scan(ctx, root, searchDirs)
+    if err != nil {
+        return nil, err
+    }
+    // 删除或重新指向链接后引用索引已过期
+    defer s.InvalidateReferences()
+
+    selected := make(map[string]bool)
+    for _, path := range req.Links {
+        selected[filepath.Clean(path)] = true
+    }
+    targets := make(map[string]string)
+    for path, target := range req.Targets {
+        targets[filepath.Clean(path)] = target
+    }
+
+    results := []models.RepairResult{}
+    for _, group := range scan.Groups {
+        for _, link := range group.Links {
+            if len(selected) > 0 && !selected[link.Path] {
+                continue
+            }
+            delete(selected, link.Path)
+            if ctx.Err() != nil {
+                return results, ErrCancelled
+            }
+
+            result := models.RepairResult{Path: link.Path, Action: req.Action}
+            switch {
+            case link.Owner == "" && !req.Force:
+                err = fmt.Errorf("不是本工具创建的链接，需指定 force 才能修复")
+            case req.Action == models.RepairDelete:
+                if err = os.Remove(link.Path); err == nil {
+                    err = s.manifest.Forget([]string{link.Path})
+                }
+            case req.Action == models.RepairRepoint:
+                result.LinkTarget, err = s.repoint(link, targets[link.Path], guard)
+            }
+            if err != nil {
+                result.Error = err.Error()
+                fmt.Printf("⚠️ 修复断链 %s 失败: %v\n", link.Path, err)
+            } else {
+                result.Success = true
+                fmt.Printf("🔧 已修复断链（%s）: %s\n", req.Action, link.Path)
+            }
+            results = append(results, result)
+        }
+    }
+
+    // 指定的链接不存在或不是断链
+    var missing []string
+    for path := range selected {
+        missing = append(missing, path)
+    }
+    sort.Strings(missing)
+    for _, path := range missing {
+        results = append(results, models.RepairResult{
+            Path:   path,
+            Action: req.Action,
+            Error:  "不是目录中的断开链接",
+        })
+    }
+    return results, nil
+}
+
+// repoint 将断链指向新的源文件，链接原来经过路径映射时使用同一映射生成链接目标
+func (s *LibraryService) repoint(link models.BrokenLink, target string, guard *PathGuard) (string, error) {
+    if target == "" {
+        if len(link.Candidates) != 1 {
+            return "", fmt.Errorf("找到 %d 个候选文件，请指定新的源文件", len(link.Candidates))
+        }
+        target = link.Candidates[0].Path
+    }
+
+    source, err := guard.Resolve(target, ScopeSource)
+    if err != nil {
+        return "", err
+    }
+    info, err := os.Stat(source)
+    if err != nil {
+        return "", fmt.Errorf("新的源文件不存在: %v", err)
+    }
+    if info.IsDir() {
+        return "", fmt.Errorf("新的源文件是目录: %s", source)
+    }
+
+    linkTarget := source
+    mapping := s.mapper.Mapping(link.PathMap)
+    if mapping != nil {
+        if mapped, ok := mapping.Map(source); ok {
+            linkTarget = mapped
+        }
+    }
+    if err := verifyLinkTarget(source, linkTarget, mapping); err != nil {
+        return "", err
+    }
+
+    // 先创建临时链接再替换，失败时保留原链接
+    tmp := link.Path + repairSuffix
+    os.Remove(tmp)
+    if err := os.Symlink(linkTarget, tmp); err != nil {
+        return "", fmt.Errorf("无法创建符号链接: %v", err)
+    }
+    if err := os.Rename(tmp, link.Path); err != nil {
+        os.Remove(tmp)
+        return "", fmt.Errorf("无法替换符号链接: %v", err)
+    }
+    // 链接已替换，清单更新失败只影响之后的修复
+    if link.Owner != "" {
+        if err := s.manifest.Relink(link.Path, source, linkTarget); err != nil {
+            fmt.Printf("⚠️ 无法更新媒体库清单: %v\n", err)
+        }
+    }
+    return linkTarget, nil
+}
+
+// sourceIndex 源目录中的文件，按文件名和大小索引
+type sourceIndex struct {
+    byName map[string][]models.LinkCandidate
+    bySize map[int64][]models.LinkCandidate
+}
+
+// indexSourceFiles 索引源目录中的所有文件（不跟随符号链接）
+func indexSourceFiles(ctx context.Context, dirs []string) (*sourceIndex, error) {
+    index := &sourceIndex{
+        byName: make(map[string][]models.LinkCandidate),
+        bySize: make(map[int64][]models.LinkCandidate),
+    }
+    for _, dir := range dirs {
+        err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+            if ctx.Err() != nil {
+                return ErrCancelled
+            }
+            if err != nil || !d.Type().IsRegular() {
+                return nil
+            }
+            info, err := d.Info()
+            if err != nil {
+                return nil
+            }
+            candidate := models.LinkCandidate{Path: path, Size: info.Size()}
+            index.byName[d.Name()] = append(index.byName[d.Name()], candidate)
+            index.bySize[info.Size()] = append(index.bySize[info.Size()], candidate)
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return index, nil
+}
+
+// find 查找断链可能指向的文件：同名文件（已知大小时优先大小相同的），
+// 没有同名文件时查找大小相同且扩展名相同的文件（源文件被重命名）
+func (idx *sourceIndex) find(link models.BrokenLink) []models.LinkCandidate {
+    name := filepath.Base(link.LocalTarget)
+    var byName, sameSize []models.LinkCandidate
+    for _, candidate := range idx.byName[name] {
+        if link.Size > 0 && candidate.Size == link.Size {
+            candidate.MatchBy = "name+size"
+            sameSize = append(sameSize, candidate)
+        } else {
+            candidate.MatchBy = "name"
+            byName = append(byName, candidate)
+        }
+    }
+    if len(sameSize) > 0 {
+        return sameSize
+    }
+    if len(byName) > 0 || link.Size <= 0 {
+        return append([]models.LinkCandidate{}, byName...)
+    }
+
+    candidates := []models.LinkCandidate{}
+    for _, candidate := range idx.bySize[link.Size] {
+        if strings.EqualFold(filepath.Ext(candidate.Path), filepath.Ext(name)) {
+            candidate.MatchBy = "size"
+            candidates = append(candidates, candidate)
+        }
+    }
+    return candidates
+}
+
+// groupBrokenLinks 按目标目录下的剧集目录和季目录分组（如 剧集名/S01/文件）
+func groupBrokenLinks(root string, links []models.BrokenLink) []models.LibraryGroup {
+    groups := []models.LibraryGroup{}
+    index := make(map[string]int)
+    for _, link := range links {
+        var series, season string
+        if rel, err := filepath.Rel(root, filepath.Dir(link.Path)); err == nil && rel != "." {
+            parts := strings.Split(rel, string(filepath.Separator))
+            series = parts[0]
+            if len(parts) > 1 {
+                season = parts[1]
+            }
+        }
+
+        key := series + "\x00" + season
+        i, ok := index[key]
+        if !ok {
+            i = len(groups)
+            index[key] = i
+            groups = append(groups, models.LibraryGroup{Series: series, Season: season})
+        }
+        groups[i].Links = append(groups[i].Links, link)
+    }
+
+    sort.Slice(groups, func(i, j int) bool {
+        if groups[i].Series != groups[j].Series {
+            return groups[i].Series < groups[j].Series
+        }
+        return groups[i].Season < groups[j].Season
+    })
+    for _, group := range groups {
+        sort.Slice(group.Links, func(i, j int) bool {
+            return group.Links[i].Path < group.Links[j].Path
+        })
+    }
+    return groups
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+    "vdsymlink-web/models"
+)
+
+// 媒体库清单文件名，保存在任务的目标目录中
+const manifestFile = ".vdsymlink.json"
+
+// 移动的文件上记录创建任务的扩展属性
+const (
+    xattrJob    = "user.vdsymlink.job"
+    xattrSource = "user.vdsymlink.source"
+)
+
+// ManifestStore 在每个媒体库目录的清单文件中记录本工具创建的链接和移动的文件，
+// 删除或修改媒体库内容前据此确认内容由本工具创建
+type ManifestStore struct {
+    mu      sync.Mutex
+    history *HistoryStore // 清单功能之前创建的链接从任务历史中查找
+    xattr   bool          // 同时在移动的文件上写入扩展属性
+    cache   map[string]cachedManifest
+}
+
+// cachedManifest 已读取的清单，文件修改后重新读取
+type cachedManifest struct {
+    modTime  time.Time
+    size     int64
+    manifest *models.Manifest
+}
+
+func NewManifestStore(history *HistoryStore, xattr bool) *ManifestStore {
+    return &ManifestStore{
+        history: history,
+        xattr:   xattr,
+        cache:   make(map[string]cachedManifest),
+    }
+}
+
+// Record 将任务创建的链接和移动的文件记录到 root 目录的清单中，删除的链接从所在的清单中移除
+func (m *ManifestStore) Record(root, jobID string, ops []models.FileOperation) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var removed []string
+    for _, op := range ops {
+        if op.Action == "unlink" && op.Status == models.OperationDone {
+            removed = append(removed, op.Target)
+        }
+    }
+    if err := m.forget(removed); err != nil {
+        return err
+    }
+
+    manifest, err := m.load(root)
+    if err != nil {
+        return err
+    }
+    changed := false
+    now := time.Now()
+    for _, op := range ops {
+        if op.Status != models.OperationDone || (op.Action != "link" && op.Action != "move") {
+            continue
+        }
+        key, ok := manifestKey(root, op.Target)
+        if !ok {
+            continue
+        }
+        manifest.Entries[key] = models.ManifestEntry{
+            JobID:      jobID,
+            Action:     op.Action,
+            Source:     op.Source,
+            LinkTarget: op.LinkTarget,
+            CreatedAt:  now,
+        }
+        changed = true
+
+        // Linux 不允许在符号链接上设置 user 扩展属性，只标记移动的文件
+        if m.xattr && op.Action == "move" {
+            if err := setXattr(op.Target, xattrJob, jobID); err != nil {
+                fmt.Printf("⚠️ 无法写入扩展属性 %s: %v\n", op.Target, err)
+            } else {
+                setXattr(op.Target, xattrSource, op.Source)
+            }
+        }
+    }
+    if !changed {
+        return nil
+    }
+    return m.save(root, manifest)
+}
+
+// Forget 从清单中移除已删除或已恢复的路径，并清除文件上的扩展属性
+func (m *ManifestStore) Forget(paths []string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.forget(paths)
+}
+
+// Relink 链接重新指向新的源文件后更新清单中的记录，记录不在清单中时写入最近的清单
+func (m *ManifestStore) Relink(path, source, linkTarget string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    dir, key, manifest := m.find(path)
+    if manifest == nil {
+        entry, _ := m.owner(path, "")
+        dir = m.root(path)
+        var err error
+        if manifest, err = m.load(dir); err != nil {
+            return err
+        }
+        key, _ = manifestKey(dir, path)
+        manifest.Entries[key] = models.ManifestEntry{JobID: entry.JobID, Action: "link", CreatedAt: time.Now()}
+    }
+    entry := manifest.Entries[key]
+    entry.Source = source
+    entry.LinkTarget = linkTarget
+    manifest.Entries[key] = entry
+    return m.save(dir, manifest)
+}
+
+// Rename 链接或文件被重命名后更新清单中的路径，记录不在清单中时忽略
+func (m *ManifestStore) Rename(from, to string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    dir, key, manifest := m.find(filepath.Clean(from))
+    if manifest == nil {
+        return nil
+    }
+    entry := manifest.Entries[key]
+    delete(manifest.Entries, key)
+    newKey, ok := manifestKey(dir, to)
+    if !ok {
+        // 移出了清单所在目录，记录到新位置所属的清单
+        if err := m.save(dir, manifest); err != nil {
+            return err
+        }
+        dir = m.root(to)
+        var err error
+        if manifest, err = m.load(dir); err != nil {
+            return err
+        }
+        newKey, _ = manifestKey(dir, to)
+    }
+    manifest.Entries[newKey] = entry
+    return m.save(dir, manifest)
+}
+
+// Root 路径所属的媒体库目录：最近的包含清单文件的上级目录，没有时为路径所在目录
+func (m *ManifestStore) Root(path string) string {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.root(path)
+}
+
+// Owner 查找路径的创建记录：依次查找所在目录及各上级目录的清单、文件的扩展属性和任务历史
+// linkTarget 不为空时链接需仍指向创建时的目标，否则视为已被手动修改
+func (m *ManifestStore) Owner(path, linkTarget string) (models.ManifestEntry, bool) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.owner(path, linkTarget)
+}
+
+// owner 调用方需持有 m.mu
+func (m *ManifestStore) owner(path, linkTarget string) (models.ManifestEntry, bool) {
+    path = filepath.Clean(path)
+    if _, key, manifest := m.find(path); manifest != nil {
+        entry := manifest.Entries[key]
+        return entry, linkTarget == "" || entry.LinkTarget == "" || entry.LinkTarget == linkTarget
+    }
+
+    if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+        if jobID, err := getXattr(path, xattrJob); err == nil && jobID != "" {
+            source, _ := getXattr(path, xattrSource)
+            return models.ManifestEntry{JobID: jobID, Action: "move", Source: source}, true
+        }
+    }
+
+    if m.history != nil {
+        if entry, ok := m.history.LinkOwner(path); ok {
+            return entry, linkTarget == "" || entry.LinkTarget == linkTarget
+        }
+    }
+    return models.ManifestEntry{}, false
+}
+
+// forget 调用方需持有 m.mu
+func (m *ManifestStore) forget(paths []string) error {
+    changed := make(map[string]*models.Manifest)
+    for _, path := range paths {
+        path = filepath.Clean(path)
+        if dir, key, manifest := m.find(path); manifest != nil {
+            delete(manifest.Entries, key)
+            changed[dir] = manifest
+        }
+        if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+            removeXattr(path, xattrJob)
+            removeXattr(path, xattrSource)
+        }
+    }
+    for dir, manifest := range changed {
+        if err := m.save(dir, manifest); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// find 在路径所在目录及各上级目录的清单中查找路径的记录
+func (m *ManifestStore) find(path string) (string, string, *models.Manifest) {
+    for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+        if manifest, err := m.load(dir); err == nil {
+            if key, ok := manifestKey(dir, path); ok {
+                if _, exists := manifest.Entries[key]; exists {
+                    return dir, key, manifest
+                }
+            }
+        }
+        if parent := filepath.Dir(dir); parent == dir {
+            return "", "", nil
+        }
+    }
+}
+
+// root 调用方需持有 m.mu
+func (m *ManifestStore) root(path string) string {
+    for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+        if _, err := os.Stat(filepath.Join(dir, manifestFile)); err == nil {
+            return dir
+        }
+        if parent := filepath.Dir(dir); parent == dir {
+            return filepath.Dir(path)
+        }
+    }
+}
+
+// load 读取目录中的清单，清单不存在时返回空清单
+func (m *ManifestStore) load(dir string) (*models.Manifest, error) {
+    path := filepath.Join(dir, manifestFile)
+    info, err := os.Stat(path)
+    if os.IsNotExist(err) {
+        delete(m.cache, path)
+        return &models.Manifest{Version: 1, Entries: make(map[string]models.ManifestEntry)}, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("无法读取媒体库清单: %v", err)
+    }
+    if cached, ok := m.cache[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+        return cached.manifest, nil
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("无法读取媒体库清单: %v", err)
+    }
+    var manifest models.Manifest
+    if err := json.Unmarshal(data, &manifest); err != nil {
+        return nil, fmt.Errorf("媒体库清单格式错误 %s: %v", path, err)
+    }
+    if manifest.Entries == nil {
+        manifest.Entries = make(map[string]models.ManifestEntry)
+    }
+    m.cache[path] = cachedManifest{modTime: info.ModTime(), size: info.Size(), manifest: &manifest}
+    return &manifest, nil
+}
+
+// save 先写入临时文件再替换清单，清单为空时删除清单文件
+func (m *ManifestStore) save(dir string, manifest *models.Manifest) error {
+    path := filepath.Join(dir, manifestFile)
+    delete(m.cache, path)
+    if len(manifest.Entries) == 0 {
+        if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+            return fmt.Errorf("无法删除媒体库清单: %v", err)
+        }
+        return nil
+    }
+
+    data, err := json.MarshalIndent(manifest, "", "  ")
+    if err != nil {
+        return fmt.Errorf("无法保存媒体库清单: %v", err)
+    }
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0644); err != nil {
+        return fmt.Errorf("无法保存媒体库清单: %v", err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        os.Remove(tmp)
+        return fmt.Errorf("无法保存媒体库清单: %v", err)
+    }
+    return nil
+}
+
+// manifestKey 路径相对清单目录的键，路径不在目录下时返回 false
+func manifestKey(dir, path string) (string, bool) {
+    rel, err := filepath.Rel(dir, filepath.Clean(path))
+    if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", false
+    }
+    return filepath.ToSlash(rel), true
+}
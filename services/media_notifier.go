@@ -0,0 +1,227 @@
+package services
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "path/filepath"
+    "strings"
+    "time"
+    "vdsymlink-web/config"
+    "vdsymlink-web/models"
+)
+
+// 媒体服务器通知的默认重试次数和首次重试间隔（之后每次翻倍）
+const (
+    defaultNotifyRetries = 3
+    notifyBackoff        = 2 * time.Second
+)
+
+// mediaServer 已校验的媒体服务器配置
+type mediaServer struct {
+    config.MediaServerConfig
+    baseURL string
+}
+
+// MediaNotifier 任务成功后通知媒体服务器刷新受影响的媒体库路径
+type MediaNotifier struct {
+    servers []*mediaServer
+    client  *http.Client
+    backoff time.Duration
+}
+
+func NewMediaNotifier(servers []config.MediaServerConfig) (*MediaNotifier, error) {
+    n := &MediaNotifier{
+        client:  &http.Client{Timeout: 30 * time.Second},
+        backoff: notifyBackoff,
+    }
+
+    for i, cfg := range servers {
+        cfg.Type = strings.ToLower(cfg.Type)
+        if cfg.Name == "" {
+            cfg.Name = fmt.Sprintf("%s-%d", cfg.Type, i+1)
+        }
+        if cfg.Type != "jellyfin" && cfg.Type != "emby" && cfg.Type != "plex" {
+            return nil, fmt.Errorf("媒体服务器 %s 的类型必须为 jellyfin、emby 或 plex", cfg.Name)
+        }
+        if cfg.URL == "" || cfg.Token == "" {
+            return nil, fmt.Errorf("媒体服务器 %s 需要填写 url 和 token", cfg.Name)
+        }
+        if cfg.Retries <= 0 {
+            cfg.Retries = defaultNotifyRetries
+        }
+        cfg.PathMappings = sortMappings(cfg.PathMappings)
+
+        n.servers = append(n.servers, &mediaServer{
+            MediaServerConfig: cfg,
+            baseURL:           strings.TrimRight(cfg.URL, "/"),
+        })
+    }
+    return n, nil
+}
+
+// Enabled 是否配置了媒体服务器
+func (n *MediaNotifier) Enabled() bool {
+    return n != nil && len(n.servers) > 0
+}
+
+// Refresh 通知所有媒体服务器刷新路径，失败时按指数退避重试
+func (n *MediaNotifier) Refresh(ctx context.Context, path string) []models.Notification {
+    var results []models.Notification
+    for _, server := range n.servers {
+        serverPath := mapPathPrefix(path, server.PathMappings)
+        result := models.Notification{
+            Server: server.Name,
+            Path:   serverPath,
+        }
+
+        backoff := n.backoff
+        for attempt := 0; attempt <= server.Retries; attempt++ {
+            if attempt > 0 {
+                select {
+                case <-time.After(backoff):
+                case <-ctx.Done():
+                }
+                backoff *= 2
+            }
+            if ctx.Err() != nil {
+                break
+            }
+
+            result.Attempts++
+            err := n.refresh(ctx, server, serverPath)
+            if err == nil {
+                result.Success = true
+                result.Error = ""
+                break
+            }
+            result.Error = err.Error()
+        }
+
+        result.At = time.Now()
+        if result.Success {
+            fmt.Printf("📺 已通知 %s 刷新: %s\n", server.Name, serverPath)
+        } else {
+            fmt.Printf("⚠️ 通知 %s 刷新失败（%d 次尝试）: %s\n", server.Name, result.Attempts, result.Error)
+        }
+        results = append(results, result)
+    }
+    return results
+}
+
+// refresh 调用媒体服务器的刷新接口
+func (n *MediaNotifier) refresh(ctx context.Context, server *mediaServer, path string) error {
+    switch server.Type {
+    case "plex":
+        return n.refreshPlex(ctx, server, path)
+    default:
+        return n.refreshJellyfin(ctx, server, path)
+    }
+}
+
+// refreshJellyfin Jellyfin 和 Emby 通过 /Library/Media/Updated 只扫描变化的路径
+func (n *MediaNotifier) refreshJellyfin(ctx context.Context, server *mediaServer, path string) error {
+    body, _ := json.Marshal(map[string]any{
+        "Updates": []map[string]string{
+            {"Path": path, "UpdateType": "Modified"},
+        },
+    })
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.baseURL+"/Library/Media/Updated", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-Emby-Token", server.Token)
+
+    _, err = n.do(req)
+    return err
+}
+
+// refreshPlex Plex 通过 /library/sections/{id}/refresh?path= 只扫描指定路径
+func (n *MediaNotifier) refreshPlex(ctx context.Context, server *mediaServer, path string) error {
+    libraryID := server.LibraryID
+    if libraryID == "" {
+        id, err := n.plexLibrary(ctx, server, path)
+        if err != nil {
+            return err
+        }
+        libraryID = id
+    }
+
+    query := url.Values{}
+    query.Set("path", path)
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+        server.baseURL+"/library/sections/"+url.PathEscape(libraryID)+"/refresh?"+query.Encode(), nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("X-Plex-Token", server.Token)
+
+    _, err = n.do(req)
+    return err
+}
+
+// plexLibrary 查找包含路径的 Plex 媒体库
+func (n *MediaNotifier) plexLibrary(ctx context.Context, server *mediaServer, path string) (string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.baseURL+"/library/sections", nil)
+    if err != nil {
+        return "", err
+    }
+    req.Header.Set("X-Plex-Token", server.Token)
+    req.Header.Set("Accept", "application/json")
+
+    body, err := n.do(req)
+    if err != nil {
+        return "", err
+    }
+    var sections struct {
+        MediaContainer struct {
+            Directory []struct {
+                Key      string `json:"key"`
+                Location []struct {
+                    Path string `json:"path"`
+                } `json:"Location"`
+            } `json:"Directory"`
+        } `json:"MediaContainer"`
+    }
+    if err := json.Unmarshal(body, &sections); err != nil {
+        return "", fmt.Errorf("Plex 媒体库列表格式错误: %v", err)
+    }
+
+    // 选择位置最长（最具体）的媒体库
+    best, bestLen := "", -1
+    for _, dir := range sections.MediaContainer.Directory {
+        for _, location := range dir.Location {
+            if isWithin(filepath.Clean(path), filepath.Clean(location.Path)) && len(location.Path) > bestLen {
+                best, bestLen = dir.Key, len(location.Path)
+            }
+        }
+    }
+    if best == "" {
+        return "", fmt.Errorf("没有包含路径 %s 的 Plex 媒体库", path)
+    }
+    return best, nil
+}
+
+// do 发送请求，非 2xx 响应视为失败
+func (n *MediaNotifier) do(req *http.Request) ([]byte, error) {
+    resp, err := n.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+    if err != nil {
+        return nil, err
+    }
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return nil, fmt.Errorf("HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+    }
+    return body, nil
+}
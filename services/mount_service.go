@@ -0,0 +1,261 @@
+package services
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "vdsymlink-web/config"
+    "vdsymlink-web/models"
+)
+
+// 当前进程的挂载信息
+const mountInfoPath = "/proc/self/mountinfo"
+
+// 不包含媒体文件的虚拟文件系统
+var virtualFSTypes = map[string]bool{
+    "proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "mqueue": true,
+    "cgroup": true, "cgroup2": true, "overlay": true, "securityfs": true, "debugfs": true,
+    "tracefs": true, "pstore": true, "bpf": true, "autofs": true, "hugetlbfs": true,
+    "fusectl": true, "configfs": true, "binfmt_misc": true, "nsfs": true, "tmpfs": true,
+}
+
+// 容器运行时挂载的系统目录和文件（如 /etc/hosts）
+var systemMountDirs = []string{"/proc", "/sys", "/dev", "/etc"}
+
+// mountEntry mountinfo 中的一行
+type mountEntry struct {
+    models.Mount
+    device  string // 磁盘设备号 major:minor
+    root    string // 挂载目录在磁盘内的路径
+    options string // 文件系统的挂载选项
+}
+
+// MountService 从 /proc/self/mountinfo 检测容器目录在宿主机上的绑定挂载来源
+type MountService struct {
+    path string
+}
+
+func NewMountService() *MountService {
+    return &MountService{path: mountInfoPath}
+}
+
+// Mounts 列出可能包含媒体文件的挂载点，按挂载路径排序
+func (s *MountService) Mounts() ([]models.Mount, error) {
+    file, err := os.Open(s.path)
+    if err != nil {
+        return nil, fmt.Errorf("无法读取挂载信息: %v", err)
+    }
+    defer file.Close()
+
+    var entries []mountEntry
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        if entry, ok := parseMountInfo(scanner.Text()); ok {
+            entries = append(entries, entry)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("无法读取挂载信息: %v", err)
+    }
+
+    // 同一路径被多次挂载时以最后一次为准
+    points := hostMountPoints(entries)
+    byPoint := make(map[string]models.Mount)
+    var order []string
+    for _, entry := range entries {
+        mount := entry.Mount
+        if !mediaMount(mount) {
+            continue
+        }
+        if mount.Bind {
+            if point, ok := points[entry.device]; ok {
+                mount.HostPath = filepath.Join(point, entry.root)
+                mount.Verified = true
+            }
+        }
+        if _, exists := byPoint[mount.MountPoint]; !exists {
+            order = append(order, mount.MountPoint)
+        }
+        byPoint[mount.MountPoint] = mount
+    }
+
+    mounts := []models.Mount{}
+    for _, point := range order {
+        mounts = append(mounts, byPoint[point])
+    }
+    return mounts, nil
+}
+
+// Suggest 查找路径所在的挂载点，计算宿主机路径和作为视频目录时的重定向路径
+func (s *MountService) Suggest(path string) (*models.MountSuggestion, error) {
+    mounts, err := s.Mounts()
+    if err != nil {
+        return nil, err
+    }
+    path = filepath.Clean(path)
+    suggestion := &models.MountSuggestion{Path: path}
+
+    mount := mountFor(path, mounts)
+    if mount == nil || !mount.Bind {
+        return suggestion, nil
+    }
+    suggestion.Mount = mount
+    suggestion.Guess = !mount.Verified
+    suggestion.HostPath = hostPath(path, mount)
+    // 重定向路径对应视频目录的上级目录
+    if parent := filepath.Dir(path); isWithin(parent, mount.MountPoint) {
+        suggestion.RedirectPath = hostPath(parent, mount)
+    }
+    return suggestion, nil
+}
+
+// Mappings 将已确认宿主机路径的绑定挂载转换为容器路径 -> 宿主机路径的路径映射
+func (s *MountService) Mappings() ([]config.PathMapping, error) {
+    mounts, err := s.Mounts()
+    if err != nil {
+        return nil, err
+    }
+    var mappings []config.PathMapping
+    for _, mount := range mounts {
+        if mount.Bind && mount.Verified && mount.HostPath != mount.MountPoint {
+            mappings = append(mappings, config.PathMapping{From: mount.MountPoint, To: mount.HostPath})
+        }
+    }
+    return mappings, nil
+}
+
+// parseMountInfo 解析 mountinfo 的一行：
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(line string) (mountEntry, bool) {
+    fields := strings.Fields(line)
+    separator := -1
+    for i, field := range fields {
+        if field == "-" {
+            separator = i
+            break
+        }
+    }
+    if separator < 6 || len(fields) < separator+3 {
+        return mountEntry{}, false
+    }
+
+    entry := mountEntry{
+        Mount: models.Mount{
+            MountPoint: unescapeMountPath(fields[4]),
+            Source:     unescapeMountPath(fields[separator+2]),
+            FSType:     fields[separator+1],
+        },
+        device: fields[2],
+        root:   unescapeMountPath(fields[3]),
+    }
+    if len(fields) > separator+3 {
+        entry.options = fields[separator+3]
+    }
+    // root 是挂载目录在磁盘内的路径，磁盘挂载在宿主机根目录以外的位置时不是宿主机路径，
+    // 先作为推测值，能确认磁盘的挂载点时再修正
+    if entry.root != "/" {
+        entry.HostPath = entry.root
+        entry.Bind = true
+    }
+    return entry, true
+}
+
+// hostMountPoints 确认磁盘在宿主机上的挂载点，返回 设备号 -> 宿主机挂载点
+// Docker 绑定挂载到容器 /etc 下的 hosts、hostname、resolv.conf 位于宿主机的 <数据目录>/containers/<容器ID>/ 中，
+// 数据目录取自根文件系统 overlay2 的 upperdir，与这些文件在磁盘内的路径比较即可得到磁盘的挂载点
+func hostMountPoints(entries []mountEntry) map[string]string {
+    dataDir := ""
+    for _, entry := range entries {
+        if entry.MountPoint != "/" || entry.FSType != "overlay" {
+            continue
+        }
+        for _, option := range strings.Split(entry.options, ",") {
+            if upper, ok := strings.CutPrefix(option, "upperdir="); ok {
+                if i := strings.Index(upper, "/overlay2/"); i > 0 {
+                    dataDir = upper[:i]
+                }
+            }
+        }
+    }
+
+    points := make(map[string]string)
+    if dataDir == "" {
+        return points
+    }
+    for _, entry := range entries {
+        if !isWithin(entry.MountPoint, "/etc") {
+            continue
+        }
+        i := strings.Index(entry.root, "/containers/")
+        if i < 0 {
+            continue
+        }
+        // 数据目录在磁盘内的路径，是数据目录宿主机路径的后缀
+        inDisk := entry.root[:i]
+        if !strings.HasSuffix(dataDir, inDisk) {
+            continue
+        }
+        point := strings.TrimSuffix(dataDir, inDisk)
+        if point == "" {
+            point = "/"
+        }
+        points[entry.device] = point
+    }
+    return points
+}
+
+// unescapeMountPath 还原 mountinfo 中转义的空格、制表符、换行和反斜杠（如 \040）
+func unescapeMountPath(path string) string {
+    if !strings.Contains(path, `\`) {
+        return path
+    }
+    var b strings.Builder
+    for i := 0; i < len(path); i++ {
+        if path[i] == '\\' && i+3 < len(path) {
+            if code, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+                b.WriteByte(byte(code))
+                i += 3
+                continue
+            }
+        }
+        b.WriteByte(path[i])
+    }
+    return b.String()
+}
+
+// mediaMount 是否为可能包含媒体文件的挂载点
+func mediaMount(mount models.Mount) bool {
+    if mount.MountPoint == "/" || virtualFSTypes[mount.FSType] {
+        return false
+    }
+    for _, dir := range systemMountDirs {
+        if isWithin(mount.MountPoint, dir) {
+            return false
+        }
+    }
+    return true
+}
+
+// mountFor 查找包含路径的最深挂载点
+func mountFor(path string, mounts []models.Mount) *models.Mount {
+    var best *models.Mount
+    for i := range mounts {
+        mount := &mounts[i]
+        if isWithin(path, mount.MountPoint) && (best == nil || len(mount.MountPoint) > len(best.MountPoint)) {
+            best = mount
+        }
+    }
+    return best
+}
+
+// hostPath 将挂载点内的路径转换为宿主机路径
+func hostPath(path string, mount *models.Mount) string {
+    rel, err := filepath.Rel(mount.MountPoint, path)
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(mount.HostPath, rel)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+)
+
+// 命名模板
+const (
+    NamingDefault  = "default"  // 剧集名/S01/剧集名.S01E01.mkv
+    NamingJellyfin = "jellyfin" // 剧集名/Season 01/剧集名 S01E01.mkv
+)
+
+// NamingTemplate 剧集文件在目标目录中的季目录名和文件名格式
+type NamingTemplate struct {
+    Name      string
+    seasonDir string // 参数：季数
+    episode   string // 参数：剧集名、季数、集数、扩展名
+}
+
+var namingTemplates = map[string]NamingTemplate{
+    NamingDefault:  {Name: NamingDefault, seasonDir: "S%s", episode: "%s.S%sE%s%s"},
+    NamingJellyfin: {Name: NamingJellyfin, seasonDir: "Season %s", episode: "%s S%sE%s%s"},
+}
+
+// 本工具生成的季目录名和剧集文件名（不含扩展名），适用于所有命名模板
+var (
+    managedSeasonDirRegex = regexp.MustCompile(`^(?:S|Season )([0-9]{2,})$`)
+    managedEpisodeRegex   = regexp.MustCompile(`^(.+?)[. ]S([0-9]{2,})E([0-9]{2,})$`)
+)
+
+// LookupNaming 按名称查找命名模板，名称为空时使用默认模板
+func LookupNaming(name string) (NamingTemplate, error) {
+    if name == "" {
+        name = NamingDefault
+    }
+    naming, ok := namingTemplates[name]
+    if !ok {
+        return NamingTemplate{}, fmt.Errorf("命名模板 %s 不存在，可用的模板: %v", name, NamingNames())
+    }
+    return naming, nil
+}
+
+// NamingNames 所有命名模板名称
+func NamingNames() []string {
+    var names []string
+    for name := range namingTemplates {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// SeasonDir 季目录名
+func (t NamingTemplate) SeasonDir(season string) string {
+    return fmt.Sprintf(t.seasonDir, season)
+}
+
+// EpisodeName 剧集文件名
+func (t NamingTemplate) EpisodeName(series, season, episode, ext string) string {
+    return fmt.Sprintf(t.episode, series, season, episode, ext)
+}
+
+// parseManagedEpisode 解析本工具生成的剧集文件名（不含扩展名），返回剧集名、季数和集数
+func parseManagedEpisode(stem string) (string, string, string, bool) {
+    match := managedEpisodeRegex.FindStringSubmatch(stem)
+    if match == nil {
+        return "", "", "", false
+    }
+    return match[1], match[2], match[3], true
+}
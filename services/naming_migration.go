@@ -0,0 +1,214 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "vdsymlink-web/models"
+)
+
+// PlanMigration 计算将 root 中本工具管理的剧集链接和文件（及同名附属文件）迁移到命名模板 naming 的计划
+// 只处理媒体库清单中记录的、位于季目录中且文件名由本工具生成的链接和文件
+func (s *SymlinkService) PlanMigration(ctx context.Context, root string, naming NamingTemplate) (*models.MigrationPlan, error) {
+    info, err := os.Stat(root)
+    if err != nil {
+        return nil, fmt.Errorf("无法访问目录: %v", err)
+    }
+    if !info.IsDir() {
+        return nil, fmt.Errorf("路径不是目录: %s", root)
+    }
+
+    plan := &models.MigrationPlan{Root: root, Naming: naming.Name, Items: []models.MigrationItem{}}
+    err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if ctx.Err() != nil {
+            return ErrCancelled
+        }
+        // 跳过无法读取的目录
+        if err != nil || !d.IsDir() {
+            return nil
+        }
+        match := managedSeasonDirRegex.FindStringSubmatch(d.Name())
+        if match == nil {
+            return nil
+        }
+        s.planSeasonDir(path, match[1], naming, plan)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    // 目标已存在或多个项目迁移到同一路径时冲突，不覆盖任何文件
+    seen := make(map[string]bool)
+    for i := range plan.Items {
+        item := &plan.Items[i]
+        if _, err := os.Lstat(item.To); err == nil {
+            item.Conflict = "目标路径已存在"
+        } else if seen[item.To] {
+            item.Conflict = "多个文件迁移到同一路径"
+        }
+        seen[item.To] = true
+        if item.Conflict != "" {
+            plan.Conflicts++
+        }
+    }
+    sort.SliceStable(plan.Items, func(i, j int) bool {
+        return plan.Items[i].From < plan.Items[j].From
+    })
+    return plan, nil
+}
+
+// planSeasonDir 计算一个季目录中本工具管理的剧集文件及其附属文件的新路径
+func (s *SymlinkService) planSeasonDir(dir, season string, naming NamingTemplate, plan *models.MigrationPlan) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return
+    }
+    newDir := filepath.Join(filepath.Dir(dir), naming.SeasonDir(season))
+
+    // 附属文件不能是本工具管理的剧集文件
+    episodes := make(map[string]bool)
+    var managed []fs.DirEntry
+    for _, entry := range entries {
+        if entry.IsDir() || !isVideoFile(entry.Name()) {
+            continue
+        }
+        path := filepath.Join(dir, entry.Name())
+        linkTarget := ""
+        if entry.Type()&fs.ModeSymlink != 0 {
+            if linkTarget, err = os.Readlink(path); err != nil {
+                continue
+            }
+        }
+        if _, owned := s.manifest.Owner(path, linkTarget); !owned {
+            continue
+        }
+        stem := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+        if _, _, _, ok := parseManagedEpisode(stem); ok {
+            episodes[entry.Name()] = true
+            managed = append(managed, entry)
+        }
+    }
+
+    for _, entry := range managed {
+        ext := filepath.Ext(entry.Name())
+        stem := strings.TrimSuffix(entry.Name(), ext)
+        series, fileSeason, episode, _ := parseManagedEpisode(stem)
+        newName := naming.EpisodeName(series, fileSeason, episode, ext)
+        newStem := strings.TrimSuffix(newName, ext)
+        from := filepath.Join(dir, entry.Name())
+        to := filepath.Join(newDir, newName)
+        if from == to {
+            plan.Unchanged++
+            continue
+        }
+
+        kind := models.MigrationFile
+        if entry.Type()&fs.ModeSymlink != 0 {
+            kind = models.MigrationLink
+        }
+        plan.Items = append(plan.Items, models.MigrationItem{From: from, To: to, Kind: kind})
+
+        // 同名的字幕、NFO、缩略图等：剧集文件名后接 . - _ 或空格
+        for _, sidecar := range entries {
+            name := sidecar.Name()
+            if sidecar.IsDir() || episodes[name] || len(name) <= len(stem) || !strings.HasPrefix(name, stem) {
+                continue
+            }
+            if !strings.ContainsRune(".-_ ", rune(name[len(stem)])) {
+                continue
+            }
+            plan.Items = append(plan.Items, models.MigrationItem{
+                From: filepath.Join(dir, name),
+                To:   filepath.Join(newDir, newStem+name[len(stem):]),
+                Kind: models.MigrationSidecar,
+            })
+        }
+    }
+}
+
+// migrateMode 按迁移计划重命名链接、文件和附属文件，每个操作记录为 rename，可通过撤销任务恢复
+func (s *SymlinkService) migrateMode(ctx context.Context, root string, naming NamingTemplate, result *processRecorder) (*models.ProcessResult, error) {
+    plan, err := s.PlanMigration(ctx, root, naming)
+    if err != nil {
+        return nil, err
+    }
+    result.result.TargetDir = root
+    fmt.Fprintf(result, "迁移到命名模板: %s\n", naming.Name)
+
+    result.start(len(plan.Items))
+    oldDirs := make(map[string]bool)
+    for i, item := range plan.Items {
+        if ctx.Err() != nil {
+            var remaining []string
+            for _, rest := range plan.Items[i:] {
+                remaining = append(remaining, rest.From)
+            }
+            result.cancelRemaining(remaining, "rename")
+            return result.finish(), ErrCancelled
+        }
+
+        op := models.FileOperation{
+            Action: "rename",
+            Source: item.From,
+            Target: item.To,
+            Status: models.OperationDone,
+        }
+        if item.Kind == models.MigrationLink {
+            op.LinkTarget, _ = os.Readlink(item.From)
+        }
+        // 预览后目标路径可能已被创建，os.Rename 会覆盖已存在的文件
+        if item.Conflict == "" {
+            if _, err := os.Lstat(item.To); err == nil {
+                item.Conflict = "目标路径已存在"
+                plan.Conflicts++
+            }
+        }
+        if item.Conflict != "" {
+            op.Status = models.OperationSkipped
+            op.Error = item.Conflict
+            fmt.Fprintf(result, "跳过 %s: %s\n", item.From, item.Conflict)
+            result.record(op)
+            continue
+        }
+
+        err := s.ensureDirectoryExists(filepath.Dir(item.To))
+        if err == nil {
+            err = os.Rename(item.From, item.To)
+        }
+        if err != nil {
+            op.Status = models.OperationFailed
+            op.Error = err.Error()
+            fmt.Fprintf(result, "错误: 无法迁移 '%s': %v\n", item.From, err)
+            result.record(op)
+            continue
+        }
+        if err := s.manifest.Rename(item.From, item.To); err != nil {
+            fmt.Fprintf(result, "警告: 无法更新媒体库清单: %v\n", err)
+        }
+        oldDirs[filepath.Dir(item.From)] = true
+        fmt.Fprintf(result, "迁移: %s -> %s\n", item.From, item.To)
+        result.record(op)
+    }
+
+    // 删除迁移后已空的旧季目录，仍有其他文件时保留
+    for dir := range oldDirs {
+        if os.Remove(dir) == nil {
+            fmt.Fprintf(result, "删除空目录: %s\n", dir)
+        }
+    }
+
+    if result.result.Processed > 0 {
+        fmt.Fprintf(result, "完成! 共迁移 %d 个文件\n", result.result.Processed)
+    } else {
+        result.WriteString("没有需要迁移的文件\n")
+    }
+    if plan.Conflicts > 0 {
+        fmt.Fprintf(result, "有 %d 个文件因冲突未迁移\n", plan.Conflicts)
+    }
+    return result.finish(), nil
+}
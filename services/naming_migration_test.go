@@ -0,0 +1,107 @@
+package services
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "vdsymlink-web/models"
+)
+
+func TestMigrationRoundTrip(t *testing.T) {
+    env := newTestEnv(t)
+    show := filepath.Join(env.source, "Show")
+    touch(t, filepath.Join(show, "Show.E01.mkv"), filepath.Join(show, "Show.E02.mkv"))
+    linked := runJob(t, env, "link", show, env.target)
+
+    oldDir := filepath.Join(env.target, "Show", "S01")
+    newDir := filepath.Join(env.target, "Show", "Season 01")
+    oldEpisode := filepath.Join(oldDir, "Show.S01E01.mkv")
+    oldSidecar := filepath.Join(oldDir, "Show.S01E01.zh.srt")
+    newEpisode := filepath.Join(newDir, "Show S01E01.mkv")
+    newSidecar := filepath.Join(newDir, "Show S01E01.zh.srt")
+    for _, op := range doneOperations(t, linked) {
+        if filepath.Dir(op.Target) != oldDir {
+            t.Fatalf("链接不在默认模板的季目录中: %s", op.Target)
+        }
+    }
+    touch(t, oldSidecar)
+    // 新模板的路径已存在时不迁移，也不覆盖已有文件
+    conflict := filepath.Join(newDir, "Show S01E02.mkv")
+    touch(t, conflict)
+
+    req := models.ProcessRequest{TargetDir: env.target, Mode: "migrate", Naming: NamingJellyfin}
+    opts := ProcessOptions{TargetDir: env.target, Mode: "migrate", Naming: NamingJellyfin}
+    job, err := env.jobs.Run(context.Background(), req, nil, opts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if job.Status != models.JobSucceeded || job.Result.Processed != 2 {
+        t.Fatalf("迁移结果 %s，迁移 %d 个: %s", job.Status, job.Result.Processed, job.Result.Log)
+    }
+    if target, err := os.Readlink(newEpisode); err != nil || target != filepath.Join(show, "Show.E01.mkv") {
+        t.Fatalf("迁移后的链接 %s -> %s: %v", newEpisode, target, err)
+    }
+    if _, err := os.Stat(newSidecar); err != nil {
+        t.Fatalf("附属文件应一起迁移: %v", err)
+    }
+    if data, _ := os.ReadFile(conflict); string(data) != filepath.Base(conflict) {
+        t.Fatal("迁移不应覆盖已存在的文件")
+    }
+    if _, err := os.Lstat(filepath.Join(oldDir, "Show.S01E02.mkv")); err != nil {
+        t.Fatalf("冲突的链接应保留在原位置: %v", err)
+    }
+    if owner, ok := env.service.manifest.Owner(newEpisode, ""); !ok || owner.JobID != linked.ID {
+        t.Fatalf("迁移后清单中的链接应仍属于创建任务: %+v", owner)
+    }
+
+    undoJob(t, env, job)
+    for _, path := range []string{oldEpisode, oldSidecar} {
+        if _, err := os.Lstat(path); err != nil {
+            t.Errorf("撤销后应恢复到原路径 %s: %v", path, err)
+        }
+    }
+    for _, path := range []string{newEpisode, newSidecar} {
+        if _, err := os.Lstat(path); !os.IsNotExist(err) {
+            t.Errorf("撤销后新路径应不存在: %s", path)
+        }
+    }
+    if _, err := os.Stat(conflict); err != nil {
+        t.Errorf("撤销不应删除迁移前已存在的文件: %v", err)
+    }
+}
+
+func TestCheckpointSurvivesRestart(t *testing.T) {
+    env := newTestEnv(t)
+    show := filepath.Join(env.source, "Show")
+    touch(t, filepath.Join(show, "Show.E01.mkv"))
+    from := filepath.Join(show, "Show.E01.mkv")
+    to := filepath.Join(show, "Show.S01E01.mkv")
+    if err := os.Rename(from, to); err != nil {
+        t.Fatal(err)
+    }
+
+    // 任务运行中写入已完成的操作后服务停止
+    job := env.jobs.create(models.ProcessRequest{SourceDir: show, Mode: "rename"}, nil)
+    env.jobs.store.Update(job.ID, func(job *models.Job) {
+        job.Status = models.JobRunning
+    })
+    env.jobs.checkpoint(job.ID, []models.FileOperation{{Action: "rename", Source: from, Target: to, Status: models.OperationDone}})
+    env.history.Close()
+
+    history, err := NewHistoryStore(filepath.Join(env.dir, "data"), 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    env.history = history
+    env.jobs = NewJobManager(env.service, NewJobStore(), history, 1, 10)
+
+    recorded, ok := env.jobs.Get(job.ID)
+    if !ok || recorded.Status != models.JobFailed || recorded.Result == nil {
+        t.Fatalf("重启后中断的任务应为失败并保留已完成的操作: %+v", recorded)
+    }
+    undoJob(t, env, recorded)
+    if _, err := os.Stat(from); err != nil {
+        t.Fatalf("撤销后文件应恢复原名: %v", err)
+    }
+}
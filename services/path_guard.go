@@ -0,0 +1,224 @@
+package services
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// 路径范围
+const (
+    ScopeSource = "source"
+    ScopeTarget = "target"
+)
+
+// PathGuard 限制可浏览和处理的路径必须位于允许的根目录下
+type PathGuard struct {
+    sourceRoots      []string
+    targetRoots      []string
+    sourceRestricted bool
+    targetRestricted bool
+}
+
+func NewPathGuard(sourceRoots, targetRoots []string) *PathGuard {
+    return &PathGuard{
+        sourceRoots:      resolveRoots(sourceRoots),
+        targetRoots:      resolveRoots(targetRoots),
+        sourceRestricted: len(sourceRoots) > 0,
+        targetRestricted: len(targetRoots) > 0,
+    }
+}
+
+// Restrict 在当前限制的基础上进一步限制根目录，返回两者的交集
+func (g *PathGuard) Restrict(sourceRoots, targetRoots []string) *PathGuard {
+    restricted := *g
+    if len(sourceRoots) > 0 {
+        restricted.sourceRoots = intersectRoots(g.sourceRoots, g.sourceRestricted, resolveRoots(sourceRoots))
+        restricted.sourceRestricted = true
+    }
+    if len(targetRoots) > 0 {
+        restricted.targetRoots = intersectRoots(g.targetRoots, g.targetRestricted, resolveRoots(targetRoots))
+        restricted.targetRestricted = true
+    }
+    return &restricted
+}
+
+// 计算两组根目录的交集：保留位于另一组根目录内的根目录
+func intersectRoots(base []string, baseRestricted bool, extra []string) []string {
+    if !baseRestricted {
+        return extra
+    }
+    var roots []string
+    seen := make(map[string]bool)
+    for _, b := range base {
+        for _, e := range extra {
+            root := ""
+            if isWithin(e, b) {
+                root = e
+            } else if isWithin(b, e) {
+                root = b
+            }
+            if root != "" && !seen[root] {
+                seen[root] = true
+                roots = append(roots, root)
+            }
+        }
+    }
+    return roots
+}
+
+// 解析根目录，忽略无法解析的路径
+func resolveRoots(roots []string) []string {
+    var resolved []string
+    for _, root := range roots {
+        path, err := resolvePath(root)
+        if err != nil {
+            fmt.Printf("⚠️ 忽略无效的根目录 %s: %v\n", root, err)
+            continue
+        }
+        resolved = append(resolved, path)
+    }
+    return resolved
+}
+
+// Roots 返回指定范围的根目录，范围为空时返回全部根目录
+func (g *PathGuard) Roots(scope string) []string {
+    switch scope {
+    case ScopeSource:
+        return g.sourceRoots
+    case ScopeTarget:
+        return g.targetRoots
+    default:
+        var roots []string
+        seen := make(map[string]bool)
+        for _, root := range append(append([]string{}, g.sourceRoots...), g.targetRoots...) {
+            if !seen[root] {
+                seen[root] = true
+                roots = append(roots, root)
+            }
+        }
+        return roots
+    }
+}
+
+// Restricted 指定范围是否配置了根目录限制
+func (g *PathGuard) Restricted(scope string) bool {
+    switch scope {
+    case ScopeSource:
+        return g.sourceRestricted
+    case ScopeTarget:
+        return g.targetRestricted
+    default:
+        return g.sourceRestricted || g.targetRestricted
+    }
+}
+
+// Resolve 规范化路径并检查是否位于指定范围的根目录下
+func (g *PathGuard) Resolve(path, scope string) (string, error) {
+    resolved, err := resolvePath(path)
+    if err != nil {
+        return "", fmt.Errorf("无法解析路径 %s: %v", path, err)
+    }
+
+    if !g.Restricted(scope) {
+        return resolved, nil
+    }
+
+    if !withinRoots(resolved, g.Roots(scope)) {
+        return "", fmt.Errorf("路径不在允许的范围内: %s", path)
+    }
+    return resolved, nil
+}
+
+// Overlaps 路径是否位于任一根目录下或包含任一根目录，源目录或目标目录未配置根目录限制时总是返回 true
+func (g *PathGuard) Overlaps(path string) bool {
+    if !g.sourceRestricted || !g.targetRestricted {
+        return true
+    }
+    for _, root := range g.Roots("") {
+        if isWithin(path, root) || isWithin(root, path) {
+            return true
+        }
+    }
+    return false
+}
+
+// Parent 返回上级目录，超出根目录边界时返回空字符串
+func (g *PathGuard) Parent(path, scope string) string {
+    parent := getParentPath(path)
+    if parent == "" {
+        return ""
+    }
+
+    if !g.Restricted(scope) {
+        return parent
+    }
+
+    resolved, err := resolvePath(parent)
+    if err != nil || !withinRoots(resolved, g.Roots(scope)) {
+        return ""
+    }
+    return parent
+}
+
+// 获取上级目录
+func getParentPath(path string) string {
+    if path == "" || path == "/" {
+        return "" // 根目录没有父目录
+    }
+
+    parent := filepath.Dir(path)
+    if parent == path {
+        return "" // 避免无限循环
+    }
+    return parent
+}
+
+// 规范化路径：转为绝对路径、清理并解析符号链接
+// 对于尚不存在的路径，解析最近的已存在上级目录后拼接剩余部分
+func resolvePath(path string) (string, error) {
+    absPath, err := filepath.Abs(path)
+    if err != nil {
+        return "", err
+    }
+    absPath = filepath.Clean(absPath)
+
+    existing := absPath
+    var rest []string
+    for {
+        resolved, err := filepath.EvalSymlinks(existing)
+        if err == nil {
+            return filepath.Join(append([]string{resolved}, rest...)...), nil
+        }
+        if !os.IsNotExist(err) {
+            return "", err
+        }
+
+        parent := filepath.Dir(existing)
+        if parent == existing {
+            return absPath, nil
+        }
+        rest = append([]string{filepath.Base(existing)}, rest...)
+        existing = parent
+    }
+}
+
+// 检查路径是否位于任一根目录下（包括根目录本身）
+func withinRoots(path string, roots []string) bool {
+    for _, root := range roots {
+        if isWithin(path, root) {
+            return true
+        }
+    }
+    return false
+}
+
+// 检查路径是否等于或位于根目录下
+func isWithin(path, root string) bool {
+    rel, err := filepath.Rel(root, path)
+    if err != nil {
+        return false
+    }
+    return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestPathGuardRejectsEscapes(t *testing.T) {
+    dir := t.TempDir()
+    source := filepath.Join(dir, "src")
+    outside := filepath.Join(dir, "outside")
+    touch(t, filepath.Join(source, "Show", "Show.E01.mkv"), filepath.Join(outside, "secret.mkv"), filepath.Join(dir, "src2", "x.mkv"))
+    if err := os.Symlink(outside, filepath.Join(source, "escape")); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Symlink(filepath.Join(source, "Show"), filepath.Join(source, "alias")); err != nil {
+        t.Fatal(err)
+    }
+    guard := NewPathGuard([]string{source}, []string{filepath.Join(dir, "lib")})
+
+    allowed := []string{
+        source,
+        filepath.Join(source, "Show"),
+        filepath.Join(source, "Show", "..", "Show", "Show.E01.mkv"),
+        filepath.Join(source, "alias"),           // 指向根目录内的链接
+        filepath.Join(source, "New", "Season 1"), // 尚不存在的路径
+    }
+    for _, path := range allowed {
+        if _, err := guard.Resolve(path, ScopeSource); err != nil {
+            t.Errorf("%s 应允许访问: %v", path, err)
+        }
+    }
+
+    denied := []string{
+        dir,
+        filepath.Join(source, ".."),
+        filepath.Join(source, "..", "outside", "secret.mkv"),
+        filepath.Join(source, "escape"),                // 指向根目录外的链接
+        filepath.Join(source, "escape", "secret.mkv"),  // 经过链接的文件
+        filepath.Join(source, "escape", "new", "file"), // 经过链接的不存在路径
+        filepath.Join(dir, "src2", "x.mkv"),            // 名称前缀相同的目录
+        "/etc/passwd",
+    }
+    for _, path := range denied {
+        if resolved, err := guard.Resolve(path, ScopeSource); err == nil {
+            t.Errorf("%s 不应允许访问，解析为 %s", path, resolved)
+        }
+    }
+
+    // 源目录不能作为目标目录
+    if _, err := guard.Resolve(filepath.Join(source, "Show"), ScopeTarget); err == nil {
+        t.Error("源目录不应作为目标目录")
+    }
+    // 不能浏览到根目录之外
+    if parent := guard.Parent(source, ScopeSource); parent != "" {
+        t.Errorf("根目录的上级目录应为空，实际 %s", parent)
+    }
+}
+
+func TestPathGuardRestrict(t *testing.T) {
+    dir := t.TempDir()
+    source := filepath.Join(dir, "src")
+    target := filepath.Join(dir, "lib")
+    touch(t, filepath.Join(source, "anime", "a.mkv"), filepath.Join(source, "movies", "b.mkv"), filepath.Join(target, "anime", "x"))
+    guard := NewPathGuard([]string{source}, []string{target})
+
+    // 角色范围与全局范围取交集
+    restricted := guard.Restrict([]string{filepath.Join(source, "anime")}, nil)
+    if _, err := restricted.Resolve(filepath.Join(source, "anime", "a.mkv"), ScopeSource); err != nil {
+        t.Errorf("角色范围内的路径应允许访问: %v", err)
+    }
+    if _, err := restricted.Resolve(filepath.Join(source, "movies", "b.mkv"), ScopeSource); err == nil {
+        t.Error("角色范围外的路径不应允许访问")
+    }
+    if _, err := restricted.Resolve(filepath.Join(target, "anime"), ScopeTarget); err != nil {
+        t.Errorf("角色未限制目标目录时使用全局范围: %v", err)
+    }
+
+    // 角色范围超出全局范围时不能扩大访问范围
+    widened := guard.Restrict([]string{dir}, nil)
+    if _, err := widened.Resolve(filepath.Join(dir, "other"), ScopeSource); err == nil {
+        t.Error("角色范围不应扩大全局范围")
+    }
+    disjoint := guard.Restrict([]string{filepath.Join(dir, "elsewhere")}, nil)
+    if _, err := disjoint.Resolve(filepath.Join(source, "anime"), ScopeSource); err == nil {
+        t.Error("角色范围与全局范围没有交集时不应允许访问任何路径")
+    }
+
+    // 挂载点与可访问的根目录重叠时才列出
+    if !restricted.Overlaps(dir) || !restricted.Overlaps(filepath.Join(source, "anime", "disk")) {
+        t.Error("包含根目录或位于根目录下的挂载点应列出")
+    }
+    if restricted.Overlaps(filepath.Join(source, "movies")) {
+        t.Error("角色范围外的挂载点不应列出")
+    }
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "path/filepath"
+    "sync"
+)
+
+// BusyError 路径正被其他任务使用
+type BusyError struct {
+    Path  string `json:"path"`
+    JobID string `json:"jobId"`
+}
+
+func (e *BusyError) Error() string {
+    return fmt.Sprintf("路径 %s 正被任务 %s 使用，请等待该任务结束后重试", e.Path, e.JobID)
+}
+
+// PathLocks 任务执行期间锁定的目录，目录与其子目录互相冲突
+type PathLocks struct {
+    mu       sync.Mutex
+    held     map[string]string // 路径 -> 持有锁的任务ID
+    released chan struct{}     // 每次释放锁时关闭并替换，用于唤醒等待者
+}
+
+func NewPathLocks() *PathLocks {
+    return &PathLocks{
+        held:     make(map[string]string),
+        released: make(chan struct{}),
+    }
+}
+
+// TryLock 为任务锁定所有路径，任一路径冲突时不锁定并返回 *BusyError
+func (l *PathLocks) TryLock(jobID string, paths []string) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.tryLock(jobID, paths)
+}
+
+// Lock 为任务锁定所有路径，冲突时等待持有者释放；ctx 取消时返回 ErrCancelled
+// 每次因冲突开始等待时调用 onWait
+func (l *PathLocks) Lock(ctx context.Context, jobID string, paths []string, onWait func(*BusyError)) error {
+    var lastHolder string
+    for {
+        l.mu.Lock()
+        err := l.tryLock(jobID, paths)
+        released := l.released
+        l.mu.Unlock()
+
+        busy, ok := err.(*BusyError)
+        if !ok {
+            return err
+        }
+        if onWait != nil && busy.JobID != lastHolder {
+            onWait(busy)
+            lastHolder = busy.JobID
+        }
+
+        select {
+        case <-released:
+        case <-ctx.Done():
+            return ErrCancelled
+        }
+    }
+}
+
+// Unlock 释放任务持有的所有路径
+func (l *PathLocks) Unlock(jobID string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    changed := false
+    for path, holder := range l.held {
+        if holder == jobID {
+            delete(l.held, path)
+            changed = true
+        }
+    }
+    if changed {
+        close(l.released)
+        l.released = make(chan struct{})
+    }
+}
+
+// tryLock 检查冲突并锁定，调用方需持有 l.mu
+func (l *PathLocks) tryLock(jobID string, paths []string) error {
+    var wanted []string
+    for _, path := range paths {
+        if path == "" {
+            continue
+        }
+        path = filepath.Clean(path)
+        for held, holder := range l.held {
+            if holder != jobID && (isWithin(path, held) || isWithin(held, path)) {
+                return &BusyError{Path: held, JobID: holder}
+            }
+        }
+        wanted = append(wanted, path)
+    }
+
+    for _, path := range wanted {
+        l.held[path] = jobID
+    }
+    return nil
+}
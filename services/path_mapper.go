@@ -0,0 +1,156 @@
+package services
+
+import (
+    "fmt"
+    "path/filepath"
+    "sort"
+    "vdsymlink-web/config"
+)
+
+// 未指定路径映射时使用的映射名称，以及由检测到的绑定挂载生成的映射名称
+const (
+    defaultPathMap = "default"
+    hostPathMap    = "host"
+)
+
+// LinkMapping 创建符号链接时将源文件路径改写为链接目标路径
+type LinkMapping struct {
+    Name     string
+    Mappings []config.PathMapping // 按前缀长度降序排列
+}
+
+// Map 按最长前缀改写路径，没有匹配的前缀时返回原路径和 false
+func (m *LinkMapping) Map(path string) (string, bool) {
+    return matchPathPrefix(path, m.Mappings)
+}
+
+// Reverse 将链接目标按最长的目标前缀转换回本服务中的路径
+func (m *LinkMapping) Reverse(path string) (string, bool) {
+    path = filepath.Clean(path)
+    best := -1
+    for i, mapping := range m.Mappings {
+        to := filepath.Clean(mapping.To)
+        if isWithin(path, to) && (best < 0 || len(to) > len(filepath.Clean(m.Mappings[best].To))) {
+            best = i
+        }
+    }
+    if best < 0 {
+        return path, false
+    }
+    rel, _ := filepath.Rel(filepath.Clean(m.Mappings[best].To), path)
+    return filepath.Join(m.Mappings[best].From, rel), true
+}
+
+// PathMapper 按名称管理多组路径映射（如宿主机、Jellyfin 容器、SMB 客户端各一组）
+type PathMapper struct {
+    maps map[string]*LinkMapping
+}
+
+func NewPathMapper(maps map[string][]config.PathMapping) (*PathMapper, error) {
+    p := &PathMapper{maps: make(map[string]*LinkMapping)}
+    for name, mappings := range maps {
+        if name == "" {
+            return nil, fmt.Errorf("路径映射名称不能为空")
+        }
+        for _, mapping := range mappings {
+            if mapping.From == "" || mapping.To == "" {
+                return nil, fmt.Errorf("路径映射 %s 需要填写 from 和 to", name)
+            }
+            if !filepath.IsAbs(mapping.From) {
+                return nil, fmt.Errorf("路径映射 %s 的 from 必须为绝对路径: %s", name, mapping.From)
+            }
+        }
+        p.maps[name] = &LinkMapping{Name: name, Mappings: sortMappings(mappings)}
+    }
+    return p, nil
+}
+
+// AddDetected 将检测到的绑定挂载添加为 host 映射（容器路径 -> 宿主机路径），已配置 host 映射时不覆盖
+func (p *PathMapper) AddDetected(mappings []config.PathMapping) bool {
+    if len(mappings) == 0 || p.maps[hostPathMap] != nil {
+        return false
+    }
+    p.maps[hostPathMap] = &LinkMapping{Name: hostPathMap, Mappings: sortMappings(mappings)}
+    return true
+}
+
+// Mapping 按名称查找路径映射
+func (p *PathMapper) Mapping(name string) *LinkMapping {
+    if p == nil {
+        return nil
+    }
+    return p.maps[name]
+}
+
+// Names 所有路径映射名称
+func (p *PathMapper) Names() []string {
+    var names []string
+    if p == nil {
+        return names
+    }
+    for name := range p.maps {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Validate 检查请求中的路径映射参数
+func (p *PathMapper) Validate(name, redirectPath string) error {
+    if name != "" && redirectPath != "" {
+        return fmt.Errorf("路径映射和重定向路径不能同时指定")
+    }
+    if name != "" && (p == nil || p.maps[name] == nil) {
+        return fmt.Errorf("路径映射 %s 不存在", name)
+    }
+    return nil
+}
+
+// ForRequest 返回处理请求使用的路径映射，不需要改写时返回 nil
+// 重定向路径视为将源目录的上级目录映射到重定向路径的单条映射
+func (p *PathMapper) ForRequest(name, redirectPath, sourceDir string) (*LinkMapping, error) {
+    if err := p.Validate(name, redirectPath); err != nil {
+        return nil, err
+    }
+    if redirectPath != "" {
+        return &LinkMapping{
+            Name:     "redirectPath",
+            Mappings: []config.PathMapping{{From: filepath.Dir(sourceDir), To: redirectPath}},
+        }, nil
+    }
+    if name == "" {
+        name = defaultPathMap
+    }
+    if p == nil {
+        return nil, nil
+    }
+    return p.maps[name], nil
+}
+
+// sortMappings 复制映射并按前缀长度降序排列，优先匹配最长的前缀
+func sortMappings(mappings []config.PathMapping) []config.PathMapping {
+    sorted := append([]config.PathMapping(nil), mappings...)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        return len(filepath.Clean(sorted[i].From)) > len(filepath.Clean(sorted[j].From))
+    })
+    return sorted
+}
+
+// matchPathPrefix 按最长前缀映射路径，mappings 需按前缀长度降序排列
+func matchPathPrefix(path string, mappings []config.PathMapping) (string, bool) {
+    path = filepath.Clean(path)
+    for _, mapping := range mappings {
+        from := filepath.Clean(mapping.From)
+        if isWithin(path, from) {
+            rel, _ := filepath.Rel(from, path)
+            return filepath.Join(mapping.To, rel), true
+        }
+    }
+    return path, false
+}
+
+// mapPathPrefix 按最长前缀将路径映射为本服务中的路径，没有匹配时返回原路径
+func mapPathPrefix(path string, mappings []config.PathMapping) string {
+    mapped, _ := matchPathPrefix(path, mappings)
+    return mapped
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+    "fmt"
+    "vdsymlink-web/config"
+)
+
+// 支持的处理模式
+var processModes = []string{"link", "move", "rename", "migrate"}
+
+// 管理员角色需要允许的处理模式，不包含后来加入的 migrate，
+// 已有的允许 link、move、rename 的角色仍为管理员
+var adminModes = []string{"link", "move", "rename"}
+
+// Role 角色：允许的处理模式和路径范围
+type Role struct {
+    Name        string
+    modes       map[string]bool
+    sourceRoots []string
+    targetRoots []string
+}
+
+// PermissionService 根据用户角色检查处理模式和路径权限
+type PermissionService struct {
+    roles map[string]*Role
+}
+
+func NewPermissionService(roles map[string]config.RoleConfig) (*PermissionService, error) {
+    s := &PermissionService{
+        roles: make(map[string]*Role),
+    }
+
+    for name, cfg := range roles {
+        role := &Role{
+            Name:        name,
+            modes:       make(map[string]bool),
+            sourceRoots: cfg.SourceRoots,
+            targetRoots: cfg.TargetRoots,
+        }
+        modes := cfg.Modes
+        if len(modes) == 0 {
+            modes = processModes
+        }
+        for _, mode := range modes {
+            if !isProcessMode(mode) {
+                return nil, fmt.Errorf("角色 %s 包含不支持的模式: %s", name, mode)
+            }
+            role.modes[mode] = true
+        }
+        s.roles[name] = role
+    }
+
+    return s, nil
+}
+
+// 检查是否为支持的处理模式
+func isProcessMode(mode string) bool {
+    for _, m := range processModes {
+        if m == mode {
+            return true
+        }
+    }
+    return false
+}
+
+// role 查找用户的角色，未启用认证或未分配角色时返回 nil 表示不限制
+func (s *PermissionService) role(user *User) (*Role, error) {
+    if user == nil || user.Role == "" {
+        return nil, nil
+    }
+    role, ok := s.roles[user.Role]
+    if !ok {
+        return nil, fmt.Errorf("用户 %s 的角色 %s 不存在", user.Username, user.Role)
+    }
+    return role, nil
+}
+
+// Guard 返回用户可访问的路径范围（全局范围与角色范围的交集）
+func (s *PermissionService) Guard(user *User, base *PathGuard) (*PathGuard, error) {
+    role, err := s.role(user)
+    if err != nil || role == nil {
+        return base, err
+    }
+    return base.Restrict(role.sourceRoots, role.targetRoots), nil
+}
+
+// IsAdmin 用户是否为管理员：未启用认证、未分配角色，或角色允许 link、move、rename 模式且不限制路径
+func (s *PermissionService) IsAdmin(user *User) bool {
+    role, err := s.role(user)
+    if err != nil {
+        return false
+    }
+    if role == nil {
+        return true
+    }
+    if len(role.sourceRoots) > 0 || len(role.targetRoots) > 0 {
+        return false
+    }
+    for _, mode := range adminModes {
+        if !role.modes[mode] {
+            return false
+        }
+    }
+    return true
+}
+
+// CheckMode 检查用户是否允许使用指定的处理模式
+func (s *PermissionService) CheckMode(user *User, mode string) error {
+    role, err := s.role(user)
+    if err != nil || role == nil {
+        return err
+    }
+    if !role.modes[mode] {
+        return fmt.Errorf("角色 %s 不允许使用 %s 模式", role.Name, mode)
+    }
+    return nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+    "path/filepath"
+    "testing"
+    "vdsymlink-web/config"
+)
+
+func TestPermissionModesAndAdmin(t *testing.T) {
+    dir := t.TempDir()
+    permissions, err := NewPermissionService(map[string]config.RoleConfig{
+        "all":     {},
+        "legacy":  {Modes: []string{"link", "move", "rename"}},
+        "linker":  {Modes: []string{"link"}},
+        "limited": {SourceRoots: []string{filepath.Join(dir, "src", "anime")}},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    user := func(role string) *User { return &User{Username: "u", Role: role} }
+
+    cases := []struct {
+        user    *User
+        admin   bool
+        allowed []string
+        denied  []string
+    }{
+        {nil, true, processModes, nil},                                                // 未启用认证
+        {user(""), true, processModes, nil},                                           // 未分配角色
+        {user("all"), true, processModes, nil},                                        // 未指定模式时允许所有模式
+        {user("legacy"), true, []string{"link", "move", "rename"}, []string{"migrate"}}, // 未列出 migrate 的角色仍为管理员
+        {user("linker"), false, []string{"link"}, []string{"move", "rename", "migrate"}},
+        {user("limited"), false, processModes, nil}, // 限制路径的角色不是管理员
+        {user("missing"), false, nil, processModes}, // 不存在的角色拒绝所有操作
+    }
+    for _, c := range cases {
+        name := "<nil>"
+        if c.user != nil {
+            name = c.user.Role
+        }
+        if admin := permissions.IsAdmin(c.user); admin != c.admin {
+            t.Errorf("角色 %q IsAdmin = %v，期望 %v", name, admin, c.admin)
+        }
+        for _, mode := range c.allowed {
+            if err := permissions.CheckMode(c.user, mode); err != nil {
+                t.Errorf("角色 %q 应允许 %s 模式: %v", name, mode, err)
+            }
+        }
+        for _, mode := range c.denied {
+            if err := permissions.CheckMode(c.user, mode); err == nil {
+                t.Errorf("角色 %q 不应允许 %s 模式", name, mode)
+            }
+        }
+    }
+
+    if _, err := NewPermissionService(map[string]config.RoleConfig{"bad": {Modes: []string{"copy"}}}); err == nil {
+        t.Error("不支持的模式应报错")
+    }
+}
+
+func TestPermissionGuard(t *testing.T) {
+    dir := t.TempDir()
+    source := filepath.Join(dir, "src")
+    touch(t, filepath.Join(source, "anime", "a.mkv"), filepath.Join(source, "movies", "b.mkv"))
+    base := NewPathGuard([]string{source}, []string{filepath.Join(dir, "lib")})
+    permissions, err := NewPermissionService(map[string]config.RoleConfig{
+        "limited": {SourceRoots: []string{filepath.Join(source, "anime")}},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if guard, err := permissions.Guard(nil, base); err != nil || guard != base {
+        t.Errorf("未分配角色时使用全局范围: %v", err)
+    }
+    guard, err := permissions.Guard(&User{Username: "u", Role: "limited"}, base)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := guard.Resolve(filepath.Join(source, "movies"), ScopeSource); err == nil {
+        t.Error("角色范围外的源目录不应允许访问")
+    }
+    if _, err := permissions.Guard(&User{Username: "u", Role: "missing"}, base); err == nil {
+        t.Error("不存在的角色应报错")
+    }
+}
+
+func TestAuthRoleResolution(t *testing.T) {
+    auth, err := NewAuthService(config.AuthConfig{
+        DefaultRole: "viewer",
+        Users:       []config.UserConfig{{Username: "admin", PasswordHash: "$2a$10$unused", Role: "all"}},
+        Tokens: []config.TokenConfig{
+            {Name: "script", User: "admin", Token: "admin-token"},
+            {Name: "ci", User: "admin", Role: "linker", Token: "ci-token"},
+            {Name: "bot", Token: "bot-token"},
+        },
+    }, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // 显式角色 > 用户配置的角色 > 默认角色
+    cases := map[string]string{"admin-token": "all", "ci-token": "linker", "bot-token": "viewer"}
+    for token, role := range cases {
+        user, ok := auth.AuthenticateToken(token)
+        if !ok || user.Role != role {
+            t.Errorf("令牌 %s 的角色: %+v，期望 %s", token, user, role)
+        }
+    }
+    if user := auth.ProxyUser("alice"); user.Role != "viewer" {
+        t.Errorf("代理用户应使用默认角色，实际 %q", user.Role)
+    }
+    if _, ok := auth.AuthenticateToken("wrong"); ok {
+        t.Error("错误的令牌不应通过认证")
+    }
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "vdsymlink-web/config"
+    "vdsymlink-web/models"
+)
+
+// 未匹配任何分类时使用的处理配置名称
+const defaultProfile = "default"
+
+// Profile 已校验的处理配置
+type Profile struct {
+    Name string
+    config.ProfileConfig
+    targetDir string // 解析后的目标目录
+}
+
+// ProfileService 按下载分类查找处理配置
+type ProfileService struct {
+    profiles   map[string]*Profile
+    categories map[string]*Profile // 分类（小写） -> 处理配置
+}
+
+func NewProfileService(profiles map[string]config.ProfileConfig, guard *PathGuard, mapper *PathMapper) (*ProfileService, error) {
+    s := &ProfileService{
+        profiles:   make(map[string]*Profile),
+        categories: make(map[string]*Profile),
+    }
+
+    for name, cfg := range profiles {
+        if cfg.Mode == "" {
+            cfg.Mode = "link"
+        }
+        if cfg.Mode != "link" && cfg.Mode != "move" {
+            return nil, fmt.Errorf("处理配置 %s 的模式必须为 link 或 move", name)
+        }
+        if cfg.TargetDir == "" {
+            return nil, fmt.Errorf("处理配置 %s 需要填写 targetDir", name)
+        }
+        if cfg.Sync && cfg.Mode != "link" {
+            return nil, fmt.Errorf("处理配置 %s: 同步仅支持 link 模式", name)
+        }
+        if err := mapper.Validate(cfg.PathMap, cfg.RedirectPath); err != nil {
+            return nil, fmt.Errorf("处理配置 %s: %v", name, err)
+        }
+        if _, err := LookupNaming(cfg.Naming); err != nil {
+            return nil, fmt.Errorf("处理配置 %s: %v", name, err)
+        }
+        targetDir, err := guard.Resolve(cfg.TargetDir, ScopeTarget)
+        if err != nil {
+            return nil, fmt.Errorf("处理配置 %s: %v", name, err)
+        }
+
+        profile := &Profile{Name: name, ProfileConfig: cfg, targetDir: targetDir}
+        s.profiles[name] = profile
+        for _, category := range cfg.Categories {
+            key := strings.ToLower(category)
+            if existing, ok := s.categories[key]; ok {
+                return nil, fmt.Errorf("分类 %s 同时属于处理配置 %s 和 %s", category, existing.Name, name)
+            }
+            s.categories[key] = profile
+        }
+    }
+
+    return s, nil
+}
+
+// ForCategory 查找分类对应的处理配置，未匹配时使用 default 配置
+func (s *ProfileService) ForCategory(category string) (*Profile, error) {
+    if profile, ok := s.categories[strings.ToLower(category)]; ok {
+        return profile, nil
+    }
+    if profile, ok := s.profiles[defaultProfile]; ok {
+        return profile, nil
+    }
+    return nil, fmt.Errorf("分类 %q 没有对应的处理配置", category)
+}
+
+// Request 使用处理配置处理源目录时的请求参数和处理选项，sourceDir 需已解析
+func (p *Profile) Request(sourceDir string) (models.ProcessRequest, ProcessOptions) {
+    req := models.ProcessRequest{
+        SourceDir:    sourceDir,
+        TargetDir:    p.TargetDir,
+        Mode:         p.Mode,
+        RedirectPath: p.RedirectPath,
+        PathMap:      p.PathMap,
+        Sync:         p.Sync,
+        Naming:       p.Naming,
+        Async:        true,
+    }
+    opts := ProcessOptions{
+        SourceDir:    sourceDir,
+        TargetDir:    p.targetDir,
+        Mode:         p.Mode,
+        RedirectPath: p.RedirectPath,
+        PathMap:      p.PathMap,
+        Sync:         p.Sync,
+        Naming:       p.Naming,
+    }
+    return req, opts
+}
+
+// Categories 所有处理配置中的分类
+func (s *ProfileService) Categories() []string {
+    var categories []string
+    for _, profile := range s.profiles {
+        categories = append(categories, profile.Categories...)
+    }
+    sort.Strings(categories)
+    return categories
+}
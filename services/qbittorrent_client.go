@@ -0,0 +1,147 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/cookiejar"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// QBTorrent qBittorrent WebUI API 返回的种子信息（只包含用到的字段）
+type QBTorrent struct {
+    Hash        string  `json:"hash"`
+    Name        string  `json:"name"`
+    Category    string  `json:"category"`
+    SavePath    string  `json:"save_path"`
+    ContentPath string  `json:"content_path"`
+    Progress    float64 `json:"progress"`
+    Tags        string  `json:"tags"`
+}
+
+// QBittorrentClient qBittorrent WebUI API 客户端，使用 Cookie 保持登录
+type QBittorrentClient struct {
+    baseURL  string
+    username string
+    password string
+    http     *http.Client
+}
+
+func NewQBittorrentClient(baseURL, username, password string) *QBittorrentClient {
+    jar, _ := cookiejar.New(nil)
+    return &QBittorrentClient{
+        baseURL:  strings.TrimRight(baseURL, "/"),
+        username: username,
+        password: password,
+        http:     &http.Client{Jar: jar, Timeout: 30 * time.Second},
+    }
+}
+
+// Login 登录 WebUI
+func (c *QBittorrentClient) Login(ctx context.Context) error {
+    form := url.Values{}
+    form.Set("username", c.username)
+    form.Set("password", c.password)
+
+    body, status, err := c.do(ctx, http.MethodPost, "/api/v2/auth/login", form)
+    if err != nil {
+        return err
+    }
+    if status != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+        return fmt.Errorf("qBittorrent 登录失败: HTTP %d %s", status, strings.TrimSpace(string(body)))
+    }
+    return nil
+}
+
+// CompletedTorrents 列出分类中已完成的种子，category 为空时列出所有分类
+func (c *QBittorrentClient) CompletedTorrents(ctx context.Context, category string) ([]QBTorrent, error) {
+    query := url.Values{}
+    query.Set("filter", "completed")
+    if category != "" {
+        query.Set("category", category)
+    }
+
+    body, err := c.call(ctx, http.MethodGet, "/api/v2/torrents/info?"+query.Encode(), nil)
+    if err != nil {
+        return nil, err
+    }
+    var torrents []QBTorrent
+    if err := json.Unmarshal(body, &torrents); err != nil {
+        return nil, fmt.Errorf("qBittorrent 返回的种子列表格式错误: %v", err)
+    }
+    return torrents, nil
+}
+
+// Torrents 列出所有种子（不限状态和分类），用于判断种子是否已从 qBittorrent 删除
+func (c *QBittorrentClient) Torrents(ctx context.Context) ([]QBTorrent, error) {
+    body, err := c.call(ctx, http.MethodGet, "/api/v2/torrents/info", nil)
+    if err != nil {
+        return nil, err
+    }
+    var torrents []QBTorrent
+    if err := json.Unmarshal(body, &torrents); err != nil {
+        return nil, fmt.Errorf("qBittorrent 返回的种子列表格式错误: %v", err)
+    }
+    return torrents, nil
+}
+
+// AddTags 为种子添加标签
+func (c *QBittorrentClient) AddTags(ctx context.Context, hashes []string, tag string) error {
+    form := url.Values{}
+    form.Set("hashes", strings.Join(hashes, "|"))
+    form.Set("tags", tag)
+    _, err := c.call(ctx, http.MethodPost, "/api/v2/torrents/addTags", form)
+    return err
+}
+
+// call 调用API，未登录（403）时登录后重试一次
+func (c *QBittorrentClient) call(ctx context.Context, method, path string, form url.Values) ([]byte, error) {
+    body, status, err := c.do(ctx, method, path, form)
+    if err != nil {
+        return nil, err
+    }
+    if status == http.StatusForbidden {
+        if err := c.Login(ctx); err != nil {
+            return nil, err
+        }
+        if body, status, err = c.do(ctx, method, path, form); err != nil {
+            return nil, err
+        }
+    }
+    if status != http.StatusOK {
+        return nil, fmt.Errorf("qBittorrent 请求 %s 失败: HTTP %d %s", path, status, strings.TrimSpace(string(body)))
+    }
+    return body, nil
+}
+
+func (c *QBittorrentClient) do(ctx context.Context, method, path string, form url.Values) ([]byte, int, error) {
+    var reader io.Reader
+    if form != nil {
+        reader = strings.NewReader(form.Encode())
+    }
+    req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+    if err != nil {
+        return nil, 0, err
+    }
+    if form != nil {
+        req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    }
+    // WebUI 开启了 CSRF 保护时要求 Referer 与主机一致
+    req.Header.Set("Referer", c.baseURL)
+
+    resp, err := c.http.Do(req)
+    if err != nil {
+        return nil, 0, fmt.Errorf("无法连接 qBittorrent: %v", err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, 32*1024*1024))
+    if err != nil {
+        return nil, 0, err
+    }
+    return body, resp.StatusCode, nil
+}
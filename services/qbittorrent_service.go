@@ -0,0 +1,306 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+    "vdsymlink-web/config"
+    "vdsymlink-web/models"
+)
+
+// qBittorrent 拉取状态文件名
+const qbittorrentStateFile = "qbittorrent_state.json"
+
+// 种子从 qBittorrent 的种子列表中消失超过该时间后才删除处理记录，
+// 避免重新校验、暂停或 qBittorrent 重启时短暂缺失的种子再次处理
+const qbPruneGrace = 7 * 24 * time.Hour
+
+// qbRecord 已处理种子的记录
+type qbRecord struct {
+    Name      string    `json:"name"`
+    JobID     string    `json:"jobId,omitempty"`
+    JobStatus string    `json:"jobStatus,omitempty"` // 任务结束后的状态
+    Tagged    bool      `json:"tagged,omitempty"`
+    Skipped   string    `json:"skipped,omitempty"` // 未处理的原因
+    At        time.Time `json:"at"`
+    Missing   time.Time `json:"missing,omitempty"` // 首次发现种子已不在 qBittorrent 中的时间
+}
+
+// qbState 持久化的拉取状态，每个种子只处理一次
+type qbState struct {
+    Initialized bool                 `json:"initialized"`
+    Processed   map[string]*qbRecord `json:"processed"` // 种子 hash -> 处理记录
+}
+
+// QBittorrentPoller 定期从 qBittorrent 拉取已完成的种子，按分类加入处理队列
+type QBittorrentPoller struct {
+    client          *QBittorrentClient
+    jobs            *JobManager
+    guard           *PathGuard
+    profiles        *ProfileService
+    categories      []string
+    mappings        []config.PathMapping
+    tag             string
+    interval        time.Duration
+    processExisting bool
+    statePath       string
+    state           qbState
+}
+
+func NewQBittorrentPoller(cfg config.QBittorrentConfig, guard *PathGuard, profiles *ProfileService, jobs *JobManager, dataDir string) (*QBittorrentPoller, error) {
+    p := &QBittorrentPoller{
+        client:          NewQBittorrentClient(cfg.URL, cfg.Username, cfg.Password),
+        jobs:            jobs,
+        guard:           guard,
+        profiles:        profiles,
+        categories:      cfg.Categories,
+        mappings:        sortMappings(cfg.PathMappings),
+        tag:             cfg.Tag,
+        interval:        time.Duration(cfg.PollSeconds) * time.Second,
+        processExisting: cfg.ProcessExisting,
+        statePath:       filepath.Join(dataDir, qbittorrentStateFile),
+    }
+    if p.interval <= 0 {
+        p.interval = time.Minute
+    }
+    if len(p.categories) == 0 {
+        p.categories = profiles.Categories()
+    }
+    if len(p.categories) == 0 {
+        return nil, fmt.Errorf("请配置 qbittorrent.categories 或在处理配置中设置分类")
+    }
+
+    if err := p.loadState(); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+// Categories 拉取的分类
+func (p *QBittorrentPoller) Categories() []string {
+    return p.categories
+}
+
+// Run 持续拉取直到 ctx 取消
+func (p *QBittorrentPoller) Run(ctx context.Context) {
+    ticker := time.NewTicker(p.interval)
+    defer ticker.Stop()
+
+    for {
+        if err := p.poll(ctx); err != nil {
+            fmt.Printf("⚠️ qBittorrent 拉取失败: %v\n", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// poll 拉取一次：处理新完成的种子，并为处理成功的种子添加标签
+func (p *QBittorrentPoller) poll(ctx context.Context) error {
+    var torrents []QBTorrent
+    for _, category := range p.categories {
+        list, err := p.client.CompletedTorrents(ctx, category)
+        if err != nil {
+            return err
+        }
+        torrents = append(torrents, list...)
+    }
+
+    changed := false
+    first := !p.state.Initialized
+    for _, torrent := range torrents {
+        if torrent.Progress < 1 || p.state.Processed[torrent.Hash] != nil {
+            continue
+        }
+
+        // 首次启用时已完成的种子只记录，不处理
+        if first && !p.processExisting {
+            p.record(torrent, "", "启用前已完成")
+            changed = true
+            continue
+        }
+        if p.process(torrent) {
+            changed = true
+        }
+    }
+    if first {
+        p.state.Initialized = true
+        changed = true
+    }
+
+    if p.tagFinished(ctx) {
+        changed = true
+    }
+    // 只根据完整的种子列表判断种子是否已删除，拉取失败时不删除记录
+    if all, err := p.client.Torrents(ctx); err != nil {
+        fmt.Printf("⚠️ qBittorrent 无法获取种子列表，跳过清理处理记录: %v\n", err)
+    } else if p.prune(all, time.Now()) {
+        changed = true
+    }
+    if changed {
+        return p.saveState()
+    }
+    return nil
+}
+
+// process 处理一个种子，返回是否记录了处理结果
+// 路径暂时无法访问（如尚未挂载）时不记录，下次拉取时重试
+func (p *QBittorrentPoller) process(torrent QBTorrent) bool {
+    profile, err := p.profiles.ForCategory(torrent.Category)
+    if err != nil {
+        p.record(torrent, "", err.Error())
+        return true
+    }
+
+    contentPath := torrent.ContentPath
+    if contentPath == "" {
+        contentPath = filepath.Join(torrent.SavePath, torrent.Name)
+    }
+    localPath := mapPathPrefix(contentPath, p.mappings)
+
+    sourcePath, err := p.guard.Resolve(localPath, ScopeSource)
+    if err != nil {
+        p.record(torrent, "", err.Error())
+        return true
+    }
+    if _, err := os.Stat(sourcePath); err != nil {
+        fmt.Printf("⚠️ qBittorrent 种子 %s 的内容路径无法访问，稍后重试: %v\n", torrent.Name, err)
+        return false
+    }
+    // 单文件种子的内容路径为视频文件，只处理该文件
+    if err := CheckSourcePath(sourcePath); err != nil {
+        p.record(torrent, "", err.Error())
+        return true
+    }
+
+    req, opts := profile.Request(sourcePath)
+    user := &User{Username: "qbittorrent:" + profile.Name, Method: "qbittorrent"}
+    job, err := p.jobs.Enqueue(req, user, opts)
+    if err != nil {
+        fmt.Printf("⚠️ qBittorrent 种子 %s 无法加入队列，稍后重试: %v\n", torrent.Name, err)
+        return false
+    }
+
+    fmt.Printf("📥 qBittorrent 种子下载完成: %s (分类 %q，配置 %s) -> 任务 %s\n", torrent.Name, torrent.Category, profile.Name, job.ID)
+    p.record(torrent, job.ID, "")
+    return true
+}
+
+// record 记录种子已处理
+func (p *QBittorrentPoller) record(torrent QBTorrent, jobID, skipped string) {
+    if skipped != "" && jobID == "" {
+        fmt.Printf("⏭️ qBittorrent 种子 %s 跳过: %s\n", torrent.Name, skipped)
+    }
+    p.state.Processed[torrent.Hash] = &qbRecord{
+        Name:    torrent.Name,
+        JobID:   jobID,
+        Skipped: skipped,
+        At:      time.Now(),
+    }
+}
+
+// tagFinished 记录已结束任务的状态，处理成功的种子添加标签
+func (p *QBittorrentPoller) tagFinished(ctx context.Context) bool {
+    changed := false
+    var hashes []string
+    for hash, record := range p.state.Processed {
+        if record.JobID == "" || record.JobStatus != "" {
+            continue
+        }
+        job, ok := p.jobs.Get(record.JobID)
+        if !ok {
+            record.JobStatus = "unknown"
+            changed = true
+            continue
+        }
+        if !job.Finished() {
+            continue
+        }
+        record.JobStatus = job.Status
+        changed = true
+        if job.Status == models.JobSucceeded && p.tag != "" {
+            hashes = append(hashes, hash)
+        }
+    }
+
+    if len(hashes) > 0 {
+        if err := p.client.AddTags(ctx, hashes, p.tag); err != nil {
+            fmt.Printf("⚠️ qBittorrent 添加标签失败: %v\n", err)
+        } else {
+            for _, hash := range hashes {
+                p.state.Processed[hash].Tagged = true
+            }
+        }
+    }
+    return changed
+}
+
+// prune 清理已从 qBittorrent 删除的种子记录：种子不在完整的种子列表中时先记录缺失时间，
+// 持续缺失超过 qbPruneGrace 后才删除；列表为空（如 qBittorrent 刚启动）时不做判断，任务尚未结束的记录保留
+func (p *QBittorrentPoller) prune(all []QBTorrent, now time.Time) bool {
+    if len(all) == 0 {
+        return false
+    }
+    present := make(map[string]bool, len(all))
+    for _, torrent := range all {
+        present[torrent.Hash] = true
+    }
+
+    changed := false
+    for hash, record := range p.state.Processed {
+        switch {
+        case present[hash]:
+            if !record.Missing.IsZero() {
+                record.Missing = time.Time{}
+                changed = true
+            }
+        case record.JobID != "" && record.JobStatus == "":
+        case record.Missing.IsZero():
+            record.Missing = now
+            changed = true
+        case now.Sub(record.Missing) >= qbPruneGrace:
+            delete(p.state.Processed, hash)
+            changed = true
+        }
+    }
+    return changed
+}
+
+// loadState 读取拉取状态
+func (p *QBittorrentPoller) loadState() error {
+    p.state.Processed = make(map[string]*qbRecord)
+
+    data, err := os.ReadFile(p.statePath)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("无法读取 qBittorrent 拉取状态: %v", err)
+    }
+    if err := json.Unmarshal(data, &p.state); err != nil {
+        return fmt.Errorf("qBittorrent 拉取状态文件格式错误: %v", err)
+    }
+    if p.state.Processed == nil {
+        p.state.Processed = make(map[string]*qbRecord)
+    }
+    return nil
+}
+
+// saveState 保存拉取状态，先写临时文件再重命名
+func (p *QBittorrentPoller) saveState() error {
+    data, err := json.MarshalIndent(p.state, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := p.statePath + ".tmp"
+    if err := os.WriteFile(tmp, data, 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, p.statePath)
+}
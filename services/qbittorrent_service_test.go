@@ -0,0 +1,132 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+    "vdsymlink-web/config"
+)
+
+// qbStub 模拟 qBittorrent WebUI API 的种子列表
+type qbStub struct {
+    mu        sync.Mutex
+    completed []QBTorrent // filter=completed 时返回的种子
+    all       []QBTorrent // 不带过滤条件时返回的种子
+}
+
+func (s *qbStub) set(completed, all []QBTorrent) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.completed, s.all = completed, all
+}
+
+func (s *qbStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    switch r.URL.Path {
+    case "/api/v2/auth/login":
+        w.Write([]byte("Ok."))
+    case "/api/v2/torrents/info":
+        list := s.all
+        if r.URL.Query().Get("filter") == "completed" {
+            list = s.completed
+        }
+        if list == nil {
+            list = []QBTorrent{}
+        }
+        json.NewEncoder(w).Encode(list)
+    case "/api/v2/torrents/addTags":
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+func newTestPoller(t *testing.T, env *testEnv, stub *qbStub) *QBittorrentPoller {
+    t.Helper()
+    server := httptest.NewServer(stub)
+    t.Cleanup(server.Close)
+
+    profiles, err := NewProfileService(map[string]config.ProfileConfig{
+        "default": {TargetDir: env.target, Categories: []string{"tv"}},
+    }, env.guard, env.mapper)
+    if err != nil {
+        t.Fatal(err)
+    }
+    poller, err := NewQBittorrentPoller(config.QBittorrentConfig{URL: server.URL}, env.guard, profiles, env.jobs, filepath.Join(env.dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return poller
+}
+
+func TestQBittorrentPollerProcessesEachTorrentOnce(t *testing.T) {
+    env := newTestEnv(t)
+    contentPath := filepath.Join(env.source, "Show")
+    touch(t, filepath.Join(contentPath, "Show.E01.mkv"), filepath.Join(contentPath, "Show.E02.mkv"))
+    show := QBTorrent{Hash: "aaa", Name: "Show", Category: "tv", ContentPath: contentPath, Progress: 1}
+    other := QBTorrent{Hash: "bbb", Name: "Other", Category: "tv", ContentPath: filepath.Join(env.source, "Other"), Progress: 0.5}
+
+    stub := &qbStub{}
+    poller := newTestPoller(t, env, stub)
+    ctx := context.Background()
+    poll := func() {
+        t.Helper()
+        if err := poller.poll(ctx); err != nil {
+            t.Fatal(err)
+        }
+    }
+    jobCount := func() int { return len(env.jobs.List("", 0, nil)) }
+
+    // 首次拉取时没有已完成的种子
+    poll()
+    stub.set([]QBTorrent{show}, []QBTorrent{show, other})
+    poll()
+    if jobCount() != 1 {
+        t.Fatalf("新完成的种子应创建 1 个任务，实际 %d 个", jobCount())
+    }
+    waitJob(t, env.jobs, poller.state.Processed["aaa"].JobID)
+    poll()
+
+    // 重新校验或暂停时种子不在已完成列表中，qBittorrent 重启时列表为空，都不能删除记录
+    stub.set(nil, []QBTorrent{show, other})
+    poll()
+    stub.set(nil, nil)
+    poll()
+    if record := poller.state.Processed["aaa"]; record == nil || !record.Missing.IsZero() {
+        t.Fatalf("种子暂时不在列表中时不应标记为缺失: %+v", record)
+    }
+
+    // 种子重新出现时不再处理
+    stub.set([]QBTorrent{show}, []QBTorrent{show, other})
+    poll()
+    if jobCount() != 1 {
+        t.Fatalf("重新出现的种子不应再次处理，任务数 %d", jobCount())
+    }
+
+    // 种子被删除后先记录缺失时间，宽限期内重新出现不会再次处理
+    stub.set(nil, []QBTorrent{other})
+    poll()
+    record := poller.state.Processed["aaa"]
+    if record == nil || record.Missing.IsZero() {
+        t.Fatalf("已删除的种子应记录缺失时间: %+v", record)
+    }
+    stub.set([]QBTorrent{show}, []QBTorrent{show, other})
+    poll()
+    if jobCount() != 1 || !poller.state.Processed["aaa"].Missing.IsZero() {
+        t.Fatalf("宽限期内重新出现的种子不应再次处理，任务数 %d", jobCount())
+    }
+
+    // 缺失超过宽限期后删除记录
+    stub.set(nil, []QBTorrent{other})
+    poll()
+    poller.state.Processed["aaa"].Missing = time.Now().Add(-qbPruneGrace - time.Minute)
+    poll()
+    if _, ok := poller.state.Processed["aaa"]; ok {
+        t.Fatal("缺失超过宽限期的种子记录应被删除")
+    }
+}
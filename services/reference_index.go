@@ -0,0 +1,322 @@
+package services
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "vdsymlink-web/models"
+)
+
+// 读取 .strm 文件的最大大小，超过时不是路径文件
+const maxStrmSize = 64 * 1024
+
+// 媒体库目录引用索引的缓存时间，任务结束或修复链接后立即失效
+const referenceIndexTTL = 5 * time.Minute
+
+// referenceCache 按媒体库目录缓存引用索引，供引用查询和目录浏览的引用计数共用
+type referenceCache struct {
+    mu         sync.Mutex
+    roots      map[string]cachedIndex
+    generation int // 每次失效时增加，失效前开始建立的索引不写入缓存
+}
+
+type cachedIndex struct {
+    index   *referenceIndex
+    builtAt time.Time
+}
+
+func newReferenceCache() *referenceCache {
+    return &referenceCache{roots: make(map[string]cachedIndex)}
+}
+
+// get 未过期的媒体库目录索引，以及当前的失效次数
+func (c *referenceCache) get(root string) (*referenceIndex, int) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if cached, ok := c.roots[root]; ok && time.Since(cached.builtAt) < referenceIndexTTL {
+        return cached.index, c.generation
+    }
+    return nil, c.generation
+}
+
+// put 缓存建立的索引，建立期间缓存已失效时不写入
+func (c *referenceCache) put(root string, index *referenceIndex, generation int, builtAt time.Time) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if generation == c.generation {
+        c.roots[root] = cachedIndex{index: index, builtAt: builtAt}
+    }
+}
+
+// invalidate 清空缓存
+func (c *referenceCache) invalidate() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.roots = make(map[string]cachedIndex)
+    c.generation++
+}
+
+// referenceIndex 媒体库目录中的符号链接、.strm 文件和普通文件
+type referenceIndex struct {
+    links  []indexedReference
+    bySize map[int64][]indexedFile // 普通文件按大小索引，用于查找硬链接
+}
+
+// indexedReference 符号链接或 .strm 文件，locals 为记录的路径直接或经路径映射反向转换后的本机路径
+type indexedReference struct {
+    path   string
+    kind   string
+    target string
+    locals []string
+}
+
+type indexedFile struct {
+    path string
+    info fs.FileInfo
+}
+
+// References 查找 roots 中引用 path（源文件或目录）下文件的符号链接、硬链接和 .strm 文件
+func (s *LibraryService) References(ctx context.Context, path string, roots []string) (*models.ReferenceReport, error) {
+    if _, err := os.Stat(path); err != nil {
+        return nil, fmt.Errorf("无法访问路径: %v", err)
+    }
+    index, err := s.indexReferences(ctx, roots)
+    if err != nil {
+        return nil, err
+    }
+
+    report := &models.ReferenceReport{
+        Path:       path,
+        Roots:      append([]string{}, roots...),
+        References: []models.SourceReference{},
+    }
+    for _, link := range index.links {
+        if local, ok := link.within(path); ok {
+            report.References = append(report.References, s.reference(link.path, link.kind, local, link.target))
+        }
+    }
+    err = walkSourceFiles(ctx, path, func(file string, info fs.FileInfo) {
+        for _, linked := range index.hardlinks(file, info) {
+            report.References = append(report.References, s.reference(linked, models.ReferenceHardlink, file, ""))
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    sort.Slice(report.References, func(i, j int) bool {
+        return report.References[i].Path < report.References[j].Path
+    })
+    report.Count = len(report.References)
+    return report, nil
+}
+
+// ReferenceCounts 统计 dir 的每个子目录中的文件在 roots 中被引用的次数，没有引用的子目录不列出
+func (s *LibraryService) ReferenceCounts(ctx context.Context, dir string, roots []string) (map[string]int, error) {
+    index, err := s.indexReferences(ctx, roots)
+    if err != nil {
+        return nil, err
+    }
+
+    counts := make(map[string]int)
+    child := func(path string) string {
+        rel, err := filepath.Rel(dir, path)
+        if err != nil {
+            return ""
+        }
+        name := strings.Split(rel, string(filepath.Separator))[0]
+        // 只统计子目录，dir 中的文件不计入
+        if name == rel {
+            return ""
+        }
+        return name
+    }
+    for _, link := range index.links {
+        if local, ok := link.within(dir); ok {
+            if name := child(local); name != "" {
+                counts[name]++
+            }
+        }
+    }
+    err = walkSourceFiles(ctx, dir, func(file string, info fs.FileInfo) {
+        if name := child(file); name != "" {
+            counts[name] += len(index.hardlinks(file, info))
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+    for name, count := range counts {
+        if count == 0 {
+            delete(counts, name)
+        }
+    }
+    return counts, nil
+}
+
+// reference 生成引用记录，并从媒体库清单中查找创建的任务
+func (s *LibraryService) reference(path, kind, source, target string) models.SourceReference {
+    ref := models.SourceReference{Path: path, Type: kind, Source: source, Target: target}
+    linkTarget := ""
+    if kind == models.ReferenceSymlink {
+        linkTarget = target
+    }
+    if owner, ok := s.manifest.Owner(path, linkTarget); ok {
+        ref.JobID = owner.JobID
+    }
+    return ref
+}
+
+// InvalidateReferences 媒体库内容变化（任务结束、修复链接）后丢弃缓存的引用索引
+func (s *LibraryService) InvalidateReferences() {
+    s.references.invalidate()
+}
+
+// indexReferences 合并 roots 中每个媒体库目录的引用索引，使用未过期的缓存
+func (s *LibraryService) indexReferences(ctx context.Context, roots []string) (*referenceIndex, error) {
+    if len(roots) == 1 {
+        return s.rootIndex(ctx, roots[0])
+    }
+    index := &referenceIndex{bySize: make(map[int64][]indexedFile)}
+    for _, root := range roots {
+        rootIndex, err := s.rootIndex(ctx, root)
+        if err != nil {
+            return nil, err
+        }
+        index.links = append(index.links, rootIndex.links...)
+        for size, files := range rootIndex.bySize {
+            index.bySize[size] = append(index.bySize[size], files...)
+        }
+    }
+    return index, nil
+}
+
+// rootIndex 媒体库目录的引用索引，缓存过期或已失效时重新遍历
+func (s *LibraryService) rootIndex(ctx context.Context, root string) (*referenceIndex, error) {
+    index, generation := s.references.get(root)
+    if index != nil {
+        return index, nil
+    }
+    builtAt := time.Now()
+    index, err := s.buildIndex(ctx, root)
+    if err != nil {
+        return nil, err
+    }
+    s.references.put(root, index, generation, builtAt)
+    return index, nil
+}
+
+// buildIndex 遍历媒体库目录（不跟随符号链接），记录符号链接、.strm 文件和普通文件
+func (s *LibraryService) buildIndex(ctx context.Context, root string) (*referenceIndex, error) {
+    index := &referenceIndex{bySize: make(map[int64][]indexedFile)}
+    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if ctx.Err() != nil {
+            return ErrCancelled
+        }
+        // 跳过无法读取的目录
+        if err != nil {
+            return nil
+        }
+        switch {
+        case d.Type()&fs.ModeSymlink != 0:
+            if target, err := os.Readlink(path); err == nil {
+                index.links = append(index.links, s.indexedReference(path, models.ReferenceSymlink, target))
+            }
+        case d.Type().IsRegular() && strings.EqualFold(filepath.Ext(path), ".strm"):
+            if target, ok := readStrm(path); ok {
+                index.links = append(index.links, s.indexedReference(path, models.ReferenceStrm, target))
+            }
+        case d.Type().IsRegular():
+            if info, err := d.Info(); err == nil {
+                index.bySize[info.Size()] = append(index.bySize[info.Size()], indexedFile{path: path, info: info})
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return index, nil
+}
+
+// indexedReference 计算链接目标直接及经每个路径映射反向转换后的本机路径
+func (s *LibraryService) indexedReference(path, kind, target string) indexedReference {
+    local := target
+    if !filepath.IsAbs(local) {
+        local = filepath.Join(filepath.Dir(path), local)
+    }
+    ref := indexedReference{path: path, kind: kind, target: target, locals: []string{filepath.Clean(local)}}
+    for _, name := range s.mapper.Names() {
+        if mapped, ok := s.mapper.Mapping(name).Reverse(local); ok {
+            ref.locals = append(ref.locals, mapped)
+        }
+    }
+    return ref
+}
+
+// within 链接指向的本机路径是否位于 path 下，返回匹配的本机路径
+func (r indexedReference) within(path string) (string, bool) {
+    for _, local := range r.locals {
+        if isWithin(local, path) {
+            return local, true
+        }
+    }
+    return "", false
+}
+
+// hardlinks 媒体库中与 file 是同一个文件的其他路径
+func (idx *referenceIndex) hardlinks(file string, info fs.FileInfo) []string {
+    var linked []string
+    for _, candidate := range idx.bySize[info.Size()] {
+        if candidate.path != file && os.SameFile(candidate.info, info) {
+            linked = append(linked, candidate.path)
+        }
+    }
+    return linked
+}
+
+// walkSourceFiles 遍历源文件或源目录中的普通文件（不跟随符号链接）
+func walkSourceFiles(ctx context.Context, path string, fn func(file string, info fs.FileInfo)) error {
+    return filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+        if ctx.Err() != nil {
+            return ErrCancelled
+        }
+        if err != nil || !d.Type().IsRegular() {
+            return nil
+        }
+        if info, err := d.Info(); err == nil {
+            fn(file, info)
+        }
+        return nil
+    })
+}
+
+// readStrm 读取 .strm 文件中的本地路径（第一个非空行，支持 file:// 前缀），内容为网络地址时返回 false
+func readStrm(path string) (string, bool) {
+    file, err := os.Open(path)
+    if err != nil {
+        return "", false
+    }
+    defer file.Close()
+    if info, err := file.Stat(); err != nil || info.Size() > maxStrmSize {
+        return "", false
+    }
+
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        line = strings.TrimPrefix(line, "file://")
+        return line, filepath.IsAbs(line)
+    }
+    return "", false
+}
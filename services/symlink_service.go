@@ -1,6 +1,8 @@
 package services
 
 import (
+    "context"
+    "errors"
     "fmt"
     "os"
     "path/filepath"
@@ -8,6 +10,7 @@ import (
     "runtime"
     "strconv"
     "strings"
+    "vdsymlink-web/models"
 )
 
 // 预编译正则表达式
@@ -24,6 +27,8 @@ var (
         `[Ss]([0-9]{1,2})[^0-9]`,                // S1_, S01E
         `第([0-9]{1,2})[季期]`,                   // 第1季, 第1期
     }
+    // 目标路径已是季目录（S01 或 Season 01）
+    targetSeasonDirRegex = regexp.MustCompile(`^([Ss][0-9]|[Ss]eason[._ -]*[0-9])`)
     precompiledEpisodeRegexes []*regexp.Regexp
     precompiledSeasonRegexes  []*regexp.Regexp
 )
@@ -34,33 +39,209 @@ func init() {
     precompiledSeasonRegexes = compilePatterns(seasonPatterns)
 }
 
-type SymlinkService struct{}
+// ErrCancelled 任务被取消（用户取消或客户端断开连接）
+var ErrCancelled = errors.New("任务已取消")
 
-func NewSymlinkService() *SymlinkService {
-    return &SymlinkService{}
+// errLinkUnchanged 同步模式下链接已指向正确的目标，无需重新创建
+var errLinkUnchanged = errors.New("链接已是最新")
+
+type SymlinkService struct {
+    mapper      *PathMapper
+    manifest    *ManifestStore // 记录创建的链接，删除或修改前确认由本工具创建
+    naming      NamingTemplate // 新建链接和文件使用的命名模板
+    strictLinks bool           // 拒绝创建无法验证的链接
+}
+
+func NewSymlinkService(mapper *PathMapper, manifest *ManifestStore, naming NamingTemplate, strictLinks bool) *SymlinkService {
+    return &SymlinkService{mapper: mapper, manifest: manifest, naming: naming, strictLinks: strictLinks}
+}
+
+// withNaming 使用指定命名模板的副本，其他设置与原服务相同
+func (s *SymlinkService) withNaming(naming NamingTemplate) *SymlinkService {
+    copied := *s
+    copied.naming = naming
+    return &copied
+}
+
+// linkOptions 创建符号链接时的路径映射和验证方式
+type linkOptions struct {
+    mapping *LinkMapping // 为 nil 时链接指向源文件路径
+    strict  bool         // 拒绝创建无法解析回源文件的链接
+    sync    bool         // 同步模式：跳过未变化的链接，删除源文件已不存在的链接
+    jobID   string       // 记录到媒体库清单中的任务ID
+}
+
+// EventFunc 处理事件回调
+type EventFunc func(event models.JobEvent)
+
+// ProcessOptions 处理参数
+type ProcessOptions struct {
+    SourceDir    string
+    TargetDir    string
+    Mode         string // "link", "move", "rename", "migrate"
+    RedirectPath string
+    PathMap      string    // 路径映射名称，为空时使用 default 映射
+    StrictLinks  bool      // 拒绝创建无法验证的链接
+    Sync         bool      // 同步目标季目录：更新变化的链接，删除源文件已不存在的链接（仅链接模式）
+    Naming       string    // 命名模板，为空时使用配置的模板；迁移模式下为迁移的目标模板
+    JobID        string    // 任务ID，记录到媒体库清单中
+    OnEvent      EventFunc // 可选，接收日志、文件操作和进度事件
+}
+
+// processRecorder 记录处理日志和每个文件的操作结果
+type processRecorder struct {
+    strings.Builder
+    result   models.ProcessResult
+    progress models.JobProgress
+    onEvent  EventFunc
+}
+
+// Write 写入日志并推送日志事件
+func (r *processRecorder) Write(p []byte) (int, error) {
+    r.emit(models.JobEvent{Type: models.EventLog, Message: string(p)})
+    return r.Builder.Write(p)
+}
+
+// WriteString 写入日志并推送日志事件
+func (r *processRecorder) WriteString(str string) (int, error) {
+    r.emit(models.JobEvent{Type: models.EventLog, Message: str})
+    return r.Builder.WriteString(str)
 }
 
-func (s *SymlinkService) ProcessFiles(sourceDir, targetDir, mode, redirectPath string) (string, error) {
-    var result strings.Builder
+// start 记录待处理文件数
+func (r *processRecorder) start(total int) {
+    r.progress.Total = total
+    r.emitProgress()
+}
+
+// record 记录文件操作
+func (r *processRecorder) record(op models.FileOperation) {
+    // 同步删除的过期链接不是待处理的文件，只计入删除数，不计入处理数和进度
+    if op.Action == "unlink" {
+        switch op.Status {
+        case models.OperationDone:
+            r.result.Removed++
+        case models.OperationFailed:
+            r.result.Failed++
+        }
+        r.result.Operations = append(r.result.Operations, op)
+        r.emit(models.JobEvent{Type: models.EventOperation, Operation: &op})
+        return
+    }
+
+    switch op.Status {
+    case models.OperationDone:
+        r.result.Processed++
+        r.progress.Succeeded++
+        if op.VerifyError != "" {
+            r.result.Dangling++
+        }
+    case models.OperationFailed:
+        r.result.Failed++
+        r.progress.Failed++
+    case models.OperationSkipped:
+        r.progress.Skipped++
+    case models.OperationCancelled:
+        r.progress.Cancelled++
+    }
+    r.result.Operations = append(r.result.Operations, op)
+    if op.Status != models.OperationCancelled {
+        r.progress.Completed++
+    }
+    r.progress.Current = filepath.Base(op.Source)
 
-    switch mode {
+    r.emit(models.JobEvent{Type: models.EventOperation, Operation: &op})
+    r.emitProgress()
+}
+
+// cancelRemaining 将未处理的文件记录为已取消
+func (r *processRecorder) cancelRemaining(files []string, action string) {
+    fmt.Fprintf(r, "任务已取消，剩余 %d 个文件未处理:\n", len(files))
+    for _, file := range files {
+        fmt.Fprintf(r, "  未处理: %s\n", file)
+        r.record(models.FileOperation{
+            Action: action,
+            Source: file,
+            Status: models.OperationCancelled,
+        })
+    }
+}
+
+// copyProgress 推送复制文件的字节进度
+func (r *processRecorder) copyProgress(file string, copied, total int64) {
+    r.emit(models.JobEvent{
+        Type:  models.EventBytes,
+        Bytes: &models.ByteProgress{File: filepath.Base(file), Copied: copied, Total: total},
+    })
+}
+
+// emitProgress 推送当前进度
+func (r *processRecorder) emitProgress() {
+    progress := r.progress
+    r.emit(models.JobEvent{Type: models.EventProgress, Progress: &progress})
+}
+
+// emit 推送事件
+func (r *processRecorder) emit(event models.JobEvent) {
+    if r.onEvent != nil {
+        r.onEvent(event)
+    }
+}
+
+// finish 生成最终结果
+func (r *processRecorder) finish() *models.ProcessResult {
+    r.result.Log = r.String()
+    return &r.result
+}
+
+func (s *SymlinkService) ProcessFiles(ctx context.Context, opts ProcessOptions) (*models.ProcessResult, error) {
+    result := &processRecorder{onEvent: opts.OnEvent}
+
+    // 请求指定的命名模板只用于本次处理
+    if opts.Naming != "" && opts.Mode != "migrate" {
+        naming, err := LookupNaming(opts.Naming)
+        if err != nil {
+            return nil, err
+        }
+        s = s.withNaming(naming)
+    }
+
+    switch opts.Mode {
     case "rename":
-        return s.renameMode(sourceDir, &result)
+        return s.renameMode(ctx, opts.SourceDir, result)
     case "link", "move":
-        return s.linkMoveMode(sourceDir, targetDir, mode == "move", redirectPath, &result)
+        links := linkOptions{strict: s.strictLinks || opts.StrictLinks, jobID: opts.JobID}
+        if opts.Mode == "link" {
+            links.sync = opts.Sync
+            var err error
+            if links.mapping, err = s.mapper.ForRequest(opts.PathMap, opts.RedirectPath, opts.SourceDir); err != nil {
+                return nil, err
+            }
+        }
+        return s.linkMoveMode(ctx, opts.SourceDir, opts.TargetDir, opts.Mode == "move", links, result)
+    case "migrate":
+        naming, err := LookupNaming(opts.Naming)
+        if err != nil {
+            return nil, err
+        }
+        return s.migrateMode(ctx, opts.TargetDir, naming, result)
     default:
-        return "", fmt.Errorf("不支持的模式: %s", mode)
+        return nil, fmt.Errorf("不支持的模式: %s", opts.Mode)
     }
 }
 
-func (s *SymlinkService) initializeProcessing(sourceDir, targetDir string, result *strings.Builder) ([]string, string, string, string, error) {
+// initializeProcessing 获取要处理的视频文件并确定剧集名、季数和最终目标目录
+// sourceFile 不为空时只处理该文件（单文件种子），剧集名取自文件名
+func (s *SymlinkService) initializeProcessing(ctx context.Context, sourceDir, targetDir, sourceFile string, result *processRecorder) ([]string, string, string, string, error) {
     absSourceDir, err := filepath.Abs(sourceDir)
     if err != nil {
         return nil, "", "", "", fmt.Errorf("无法获取绝对路径: %v", err)
     }
 
-    videoFiles, err := s.getVideoFiles(absSourceDir)
-    if err != nil {
+    var videoFiles []string
+    if sourceFile != "" {
+        videoFiles = []string{sourceFile}
+    } else if videoFiles, err = s.getVideoFiles(ctx, absSourceDir); err != nil {
         return nil, "", "", "", err
     }
 
@@ -75,20 +256,29 @@ func (s *SymlinkService) initializeProcessing(sourceDir, targetDir string, resul
     }
 
     seriesName, seasonNumber, finalTargetDir := s.getSeriesInfo(absSourceDir, effectiveTargetDir, videoFiles)
+    if sourceFile != "" && !targetSeasonDirRegex.MatchString(filepath.Base(effectiveTargetDir)) {
+        seriesName = strings.TrimSuffix(filepath.Base(sourceFile), filepath.Ext(sourceFile))
+    }
 
     fmt.Fprintf(result, "使用季数: S%s\n", seasonNumber)
     fmt.Fprintf(result, "使用剧集名: %s\n", seriesName)
+    result.result.SeriesName = seriesName
+    result.result.Season = seasonNumber
+    result.result.TargetDir = finalTargetDir
 
     return videoFiles, seriesName, seasonNumber, finalTargetDir, nil
 }
 
-func (s *SymlinkService) renameMode(sourceDir string, result *strings.Builder) (string, error) {
-    videoFiles, seriesName, seasonNumber, finalTargetDir, err := s.initializeProcessing(sourceDir, sourceDir, result)
+func (s *SymlinkService) renameMode(ctx context.Context, sourceDir string, result *processRecorder) (*models.ProcessResult, error) {
+    videoFiles, seriesName, seasonNumber, finalTargetDir, err := s.initializeProcessing(ctx, sourceDir, sourceDir, "", result)
     if err != nil {
-        return "", err
+        return nil, err
     }
 
-    processedFiles := s.processFiles(videoFiles, finalTargetDir, seriesName, seasonNumber, true, true, "", result)
+    processedFiles, err := s.processFiles(ctx, videoFiles, finalTargetDir, seriesName, seasonNumber, true, true, linkOptions{}, result)
+    if err != nil {
+        return result.finish(), err
+    }
 
     if processedFiles > 0 {
         fmt.Fprintf(result, "完成! 共重命名了 %d 个文件\n", processedFiles)
@@ -96,30 +286,56 @@ func (s *SymlinkService) renameMode(sourceDir string, result *strings.Builder) (
         result.WriteString("所有文件都已正确命名，无需处理\n")
     }
 
-    return result.String(), nil
+    return result.finish(), nil
 }
 
-func (s *SymlinkService) linkMoveMode(sourceDir, targetDir string, moveFiles bool, redirectPath string, result *strings.Builder) (string, error) {
+func (s *SymlinkService) linkMoveMode(ctx context.Context, sourceDir, targetDir string, moveFiles bool, links linkOptions, result *processRecorder) (*models.ProcessResult, error) {
+    // 源路径为单个视频文件时（单文件种子）只处理该文件
+    sourceFile := ""
+    if info, err := os.Stat(sourceDir); err == nil && !info.IsDir() {
+        if !isVideoFile(sourceDir) {
+            return nil, fmt.Errorf("源路径不是目录或视频文件 (.mkv 或 .mp4): %s", sourceDir)
+        }
+        sourceFile, sourceDir = sourceDir, filepath.Dir(sourceDir)
+        if links.sync {
+            // 源文件所在目录中的其他文件不属于本次处理，不删除过期链接
+            result.WriteString("源路径为单个文件，不同步删除过期链接\n")
+            links.sync = false
+        }
+    }
+
     if err := s.validatePaths(sourceDir, targetDir); err != nil {
-        return "", err
+        return nil, err
     }
 
     if err := s.ensureDirectoryExists(targetDir); err != nil {
-        return "", fmt.Errorf("无法创建目标目录: %v", err)
+        return nil, fmt.Errorf("无法创建目标目录: %v", err)
     }
 
-    videoFiles, seriesName, seasonNumber, finalTargetDir, err := s.initializeProcessing(sourceDir, targetDir, result)
+    videoFiles, seriesName, seasonNumber, finalTargetDir, err := s.initializeProcessing(ctx, sourceDir, targetDir, sourceFile, result)
     if err != nil {
-        return "", err
+        return nil, err
     }
 
-    // 只在有重定向路径时显示源文件路径
-    if redirectPath != "" {
+    // 只在有路径映射时显示源文件路径
+    if linkMap := links.mapping; linkMap != nil {
         fmt.Fprintf(result, "源文件路径: %s\n", sourceDir)
-        fmt.Fprintf(result, "使用重定向路径: %s\n", redirectPath)
+        fmt.Fprintf(result, "使用路径映射: %s\n", linkMap.Name)
+        for _, mapping := range linkMap.Mappings {
+            fmt.Fprintf(result, "  %s -> %s\n", mapping.From, mapping.To)
+        }
     }
 
-    processedFiles := s.processFiles(videoFiles, finalTargetDir, seriesName, seasonNumber, moveFiles, false, redirectPath, result)
+    processedFiles, err := s.processFiles(ctx, videoFiles, finalTargetDir, seriesName, seasonNumber, moveFiles, false, links, result)
+    removed := 0
+    if err == nil && links.sync {
+        removed = s.removeStaleLinks(sourceDir, finalTargetDir, links, result)
+    }
+    // 取消时也记录已完成的操作
+    s.recordManifest(targetDir, links.jobID, result)
+    if err != nil {
+        return result.finish(), err
+    }
 
     action := "创建链接"
     if moveFiles {
@@ -130,8 +346,22 @@ func (s *SymlinkService) linkMoveMode(sourceDir, targetDir string, moveFiles boo
     } else {
         fmt.Fprintf(result, "没有文件需要%s\n", action)
     }
+    if removed > 0 {
+        fmt.Fprintf(result, "共删除过期链接 %d 个\n", removed)
+    }
+
+    return result.finish(), nil
+}
 
-    return result.String(), nil
+// recordManifest 将本次创建的链接、移动的文件和删除的链接记录到目标目录的媒体库清单
+func (s *SymlinkService) recordManifest(targetDir, jobID string, result *processRecorder) {
+    root, err := filepath.Abs(targetDir)
+    if err == nil {
+        err = s.manifest.Record(root, jobID, result.result.Operations)
+    }
+    if err != nil {
+        fmt.Fprintf(result, "警告: 无法更新媒体库清单: %v\n", err)
+    }
 }
 
 // 编译正则表达式模式
@@ -201,33 +431,39 @@ func extractSeasonFromFilename(filename string) (string, bool) {
     return "", false
 }
 
-// 生成新文件名
-func generateNewFilename(originalName, seriesName, seasonNumber, fileExtension string, isMovie bool) string {
+// 按命名模板生成新文件名
+func generateNewFilename(naming NamingTemplate, originalName, seriesName, seasonNumber, fileExtension string, isMovie bool) string {
     if isMovie {
         return seriesName + fileExtension
     }
 
     if episodeNumber, found := extractEpisodeNumber(originalName); found {
-        return fmt.Sprintf("%s.S%sE%s%s", seriesName, seasonNumber, episodeNumber, fileExtension)
+        return naming.EpisodeName(seriesName, seasonNumber, episodeNumber, fileExtension)
     }
 
     return originalName
 }
 
 // 获取视频文件列表
-func (s *SymlinkService) getVideoFiles(sourceDir string) ([]string, error) {
+func (s *SymlinkService) getVideoFiles(ctx context.Context, sourceDir string) ([]string, error) {
     var videoFiles []string
 
+    if ctx.Err() != nil {
+        return nil, ErrCancelled
+    }
+
     entries, err := os.ReadDir(sourceDir)
     if err != nil {
         return nil, fmt.Errorf("无法读取源目录: %v", err)
     }
 
     for _, entry := range entries {
+        if ctx.Err() != nil {
+            return nil, ErrCancelled
+        }
         if !entry.IsDir() {
             filename := entry.Name()
-            ext := strings.ToLower(filepath.Ext(filename))
-            if ext == ".mkv" || ext == ".mp4" {
+            if isVideoFile(filename) {
                 fullPath := filepath.Join(sourceDir, filename)
                 videoFiles = append(videoFiles, fullPath)
             }
@@ -237,6 +473,24 @@ func (s *SymlinkService) getVideoFiles(sourceDir string) ([]string, error) {
     return videoFiles, nil
 }
 
+// CheckSourcePath 检查源路径是否可以链接或移动：目录或单个视频文件（如单文件种子）
+func CheckSourcePath(path string) error {
+    info, err := os.Stat(path)
+    if err != nil {
+        return fmt.Errorf("无法访问源路径: %v", err)
+    }
+    if !info.IsDir() && !isVideoFile(path) {
+        return fmt.Errorf("源路径不是目录或视频文件 (.mkv 或 .mp4): %s", path)
+    }
+    return nil
+}
+
+// 是否为支持的视频文件
+func isVideoFile(filename string) bool {
+    ext := strings.ToLower(filepath.Ext(filename))
+    return ext == ".mkv" || ext == ".mp4"
+}
+
 // 检测季数
 func (s *SymlinkService) detectSeason(targetDir string, videoFiles []string) string {
     basename := filepath.Base(targetDir)
@@ -300,9 +554,9 @@ func (s *SymlinkService) determineSeriesTargetDir(sourceDir, targetDir, seasonNu
     var finalDir string
 
     if targetBasename == sourceBasename {
-        finalDir = filepath.Join(targetDir, "S"+seasonNumber)
+        finalDir = filepath.Join(targetDir, s.naming.SeasonDir(seasonNumber))
     } else {
-        finalDir = filepath.Join(targetDir, sourceBasename, "S"+seasonNumber)
+        finalDir = filepath.Join(targetDir, sourceBasename, s.naming.SeasonDir(seasonNumber))
     }
 
     // 确保目录被创建
@@ -369,44 +623,127 @@ func (s *SymlinkService) handleFileConflict(targetFile string) error {
 }
 
 // 处理单个文件
-func (s *SymlinkService) processSingleFile(file, finalTargetDir, seriesName, seasonNumber string, moveFiles bool, isMovie bool, isRenameMode bool, redirectPath string, result *strings.Builder) (bool, error) {
+func (s *SymlinkService) processSingleFile(ctx context.Context, file, finalTargetDir, seriesName, seasonNumber string, moveFiles bool, isMovie bool, isRenameMode bool, links linkOptions, result *processRecorder) (bool, error) {
     filename := filepath.Base(file)
     fileExtension := filepath.Ext(filename)
 
-    newFilename := generateNewFilename(filename, seriesName, seasonNumber, fileExtension, isMovie)
+    newFilename := generateNewFilename(s.naming, filename, seriesName, seasonNumber, fileExtension, isMovie)
     targetFile := filepath.Join(finalTargetDir, newFilename)
 
     // 在重命名模式下，如果新旧文件名相同，说明文件已经正确命名，跳过
     if isRenameMode && filename == newFilename {
         fmt.Fprintf(result, "文件 '%s' 已正确命名，跳过\n", filename)
+        result.record(models.FileOperation{
+            Action: operationAction(moveFiles, isRenameMode),
+            Source: file,
+            Target: targetFile,
+            Status: models.OperationSkipped,
+        })
         return false, nil
     }
 
-    // 处理文件冲突
-    if err := s.handleFileConflict(targetFile); err != nil {
-        return false, err
-    }
-
     // 移动或创建符号链接
-    err := s.linkMoveFile(file, targetFile, moveFiles, filename, newFilename, isRenameMode, redirectPath, result)
+    err := s.linkMoveFile(ctx, file, targetFile, moveFiles, filename, newFilename, isRenameMode, links, result)
+    if err == errLinkUnchanged {
+        return false, nil
+    }
+    if err != nil {
+        op := models.FileOperation{
+            Action: operationAction(moveFiles, isRenameMode),
+            Source: file,
+            Target: targetFile,
+            Status: models.OperationFailed,
+            Error:  err.Error(),
+        }
+        // 复制过程中被取消时，已删除不完整的目标文件，源文件保持原状
+        if ctx.Err() != nil {
+            op.Status = models.OperationCancelled
+            op.Error = "已取消，文件保持原状"
+        }
+        result.record(op)
+    }
     return err == nil, err
 }
 
+// 操作类型
+func operationAction(moveFiles, isRenameMode bool) string {
+    switch {
+    case isRenameMode:
+        return "rename"
+    case moveFiles:
+        return "move"
+    default:
+        return "link"
+    }
+}
+
 // 移动或链接文件
-func (s *SymlinkService) linkMoveFile(source, target string, moveFiles bool, originalName, newName string, isRenameMode bool, redirectPath string, result *strings.Builder) error {
+func (s *SymlinkService) linkMoveFile(ctx context.Context, source, target string, moveFiles bool, originalName, newName string, isRenameMode bool, links linkOptions, result *processRecorder) error {
     var err error
+    op := models.FileOperation{
+        Action: operationAction(moveFiles, isRenameMode),
+        Source: source,
+        Target: target,
+        Status: models.OperationDone,
+    }
+    if info, err := os.Stat(source); err == nil {
+        op.Size = info.Size()
+    }
 
     if moveFiles {
+        // 处理文件冲突
+        if err := s.handleFileConflict(target); err != nil {
+            return err
+        }
         // 移动文件：source -> target
-        err = os.Rename(source, target)
+        err = s.moveFile(ctx, source, target, result)
     } else {
         // 创建符号链接：target -> source（新文件指向原始文件）
         linkTarget := source
-        if redirectPath != "" {
-            // 计算重定向路径
-            linkTarget = s.calculateRedirectPath(source, redirectPath)
+        if linkMap := links.mapping; linkMap != nil {
+            // 按路径映射改写链接目标，未匹配时指向原路径
+            mapped, ok := linkMap.Map(source)
+            if ok {
+                linkTarget = mapped
+            } else {
+                fmt.Fprintf(result, "警告: %s 不在路径映射 %s 的任何前缀下，链接指向原路径\n", source, linkMap.Name)
+            }
+        }
+        op.LinkTarget = linkTarget
+
+        // 验证链接目标能否直接或通过路径映射解析回源文件
+        verifyErr := verifyLinkTarget(source, linkTarget, links.mapping)
+        if verifyErr != nil && links.strict {
+            return fmt.Errorf("拒绝创建无法验证的链接 '%s': %v", newName, verifyErr)
+        }
+
+        // 记录被替换的链接目标，撤销时恢复；同步模式下目标未变化的链接不重新创建
+        if current, err := os.Readlink(target); err == nil {
+            if links.sync && current == linkTarget {
+                op.Status = models.OperationSkipped
+                fmt.Fprintf(result, "链接已是最新: %s\n", target)
+                result.record(op)
+                return errLinkUnchanged
+            }
+            op.ReplacedTarget = current
+            if owner, ok := s.manifest.Owner(target, current); ok {
+                op.Owner = owner.JobID
+            }
+        }
+
+        // 验证通过后再处理文件冲突，拒绝创建时保留已有的链接
+        if err := s.handleFileConflict(target); err != nil {
+            return err
         }
         err = os.Symlink(linkTarget, target)
+        if err == nil {
+            if verifyErr != nil {
+                op.VerifyError = verifyErr.Error()
+                fmt.Fprintf(result, "警告: 链接 %s 无法验证: %v\n", target, verifyErr)
+            } else {
+                op.Verified = true
+            }
+        }
     }
 
     if err != nil {
@@ -426,24 +763,36 @@ func (s *SymlinkService) linkMoveFile(source, target string, moveFiles bool, ori
             fmt.Fprintf(result, "%s: %s -> %s\n", action, source, target)
         } else {
             // 创建链接显示完整路径
-            linkTarget := source
-            if redirectPath != "" {
-                linkTarget = s.calculateRedirectPath(source, redirectPath)
+            if op.ReplacedTarget != "" {
+                action = "更新链接"
             }
-            fmt.Fprintf(result, "%s: %s -> %s\n", action, target, linkTarget)
+            fmt.Fprintf(result, "%s: %s -> %s\n", action, target, op.LinkTarget)
         }
     }
+    result.record(op)
     return nil
 }
 
-// 计算重定向路径
-func (s *SymlinkService) calculateRedirectPath(originalPath, redirectPath string) string {
-    // 获取源文件的直接父目录名
-    parentDir := filepath.Base(filepath.Dir(originalPath))
-    filename := filepath.Base(originalPath)
+// verifyLinkTarget 检查链接目标是否指向源文件：链接目标在本机存在，
+// 或通过路径映射反向转换后存在，且与源文件是同一个文件
+func verifyLinkTarget(source, linkTarget string, mapping *LinkMapping) error {
+    sourceInfo, err := os.Stat(source)
+    if err != nil {
+        return fmt.Errorf("源文件不存在: %v", err)
+    }
 
-    // 组合：重定向路径 + 父目录名 + 文件名
-    return filepath.Join(redirectPath, parentDir, filename)
+    candidates := []string{linkTarget}
+    if mapping != nil {
+        if local, ok := mapping.Reverse(linkTarget); ok {
+            candidates = append(candidates, local)
+        }
+    }
+    for _, candidate := range candidates {
+        if info, err := os.Stat(candidate); err == nil && os.SameFile(info, sourceInfo) {
+            return nil
+        }
+    }
+    return fmt.Errorf("链接目标 %s 无法解析到源文件", linkTarget)
 }
 
 // 格式化文件操作错误信息
@@ -460,19 +809,27 @@ func (s *SymlinkService) formatFileOperationError(err error, moveFiles bool, fil
 }
 
 // 处理文件（移动或创建链接）
-func (s *SymlinkService) processFiles(videoFiles []string, finalTargetDir, seriesName, seasonNumber string, moveFiles bool, isRenameMode bool, redirectPath string, result *strings.Builder) int {
+func (s *SymlinkService) processFiles(ctx context.Context, videoFiles []string, finalTargetDir, seriesName, seasonNumber string, moveFiles bool, isRenameMode bool, links linkOptions, result *processRecorder) (int, error) {
     processedFiles := 0
 
     // 确保目标目录存在
     if err := s.ensureDirectoryExists(finalTargetDir); err != nil {
         fmt.Fprintf(result, "错误: %v\n", err)
-        return 0
+        return 0, nil
     }
 
-    isMovie := len(videoFiles) == 1
+    // 目标是季目录时单个文件也是剧集（如只有一集的新季）
+    isMovie := len(videoFiles) == 1 && !targetSeasonDirRegex.MatchString(filepath.Base(finalTargetDir))
+    result.start(len(videoFiles))
 
-    for _, file := range videoFiles {
-        processed, err := s.processSingleFile(file, finalTargetDir, seriesName, seasonNumber, moveFiles, isMovie, isRenameMode, redirectPath, result)
+    for i, file := range videoFiles {
+        // 只在两个文件之间响应取消，保证单个文件的操作完整
+        if ctx.Err() != nil {
+            result.cancelRemaining(videoFiles[i:], operationAction(moveFiles, isRenameMode))
+            return processedFiles, ErrCancelled
+        }
+
+        processed, err := s.processSingleFile(ctx, file, finalTargetDir, seriesName, seasonNumber, moveFiles, isMovie, isRenameMode, links, result)
         if err != nil {
             fmt.Fprintf(result, "错误: %v\n", err)
             continue
@@ -482,7 +839,70 @@ func (s *SymlinkService) processFiles(videoFiles []string, finalTargetDir, serie
         }
     }
 
-    return processedFiles
+    return processedFiles, nil
+}
+
+// removeStaleLinks 同步模式下删除目标目录中指向源目录、但源文件已不在本次处理中的链接，
+// 只删除由本工具创建的链接，返回删除的数量
+func (s *SymlinkService) removeStaleLinks(sourceDir, finalTargetDir string, links linkOptions, result *processRecorder) int {
+    // 本次处理涉及的链接和源文件
+    targets := make(map[string]bool)
+    sources := make(map[string]bool)
+    for _, op := range result.result.Operations {
+        targets[op.Target] = true
+        sources[op.Source] = true
+    }
+
+    entries, err := os.ReadDir(finalTargetDir)
+    if err != nil {
+        fmt.Fprintf(result, "错误: 无法读取目标目录: %v\n", err)
+        return 0
+    }
+
+    removed := 0
+    for _, entry := range entries {
+        path := filepath.Join(finalTargetDir, entry.Name())
+        if entry.Type()&os.ModeSymlink == 0 || targets[path] {
+            continue
+        }
+        linkTarget, err := os.Readlink(path)
+        if err != nil {
+            continue
+        }
+        local := linkTarget
+        if links.mapping != nil {
+            if mapped, ok := links.mapping.Reverse(linkTarget); ok {
+                local = mapped
+            }
+        }
+        if !isWithin(filepath.Clean(local), sourceDir) || sources[filepath.Clean(local)] {
+            continue
+        }
+        owner, owned := s.manifest.Owner(path, linkTarget)
+        if !owned {
+            fmt.Fprintf(result, "保留非本工具创建的链接: %s\n", path)
+            continue
+        }
+
+        op := models.FileOperation{
+            Action:     "unlink",
+            Source:     local,
+            Target:     path,
+            LinkTarget: linkTarget,
+            Status:     models.OperationDone,
+            Owner:      owner.JobID,
+        }
+        if err := os.Remove(path); err != nil {
+            op.Status = models.OperationFailed
+            op.Error = err.Error()
+            fmt.Fprintf(result, "错误: 无法删除链接 '%s': %v\n", path, err)
+        } else {
+            fmt.Fprintf(result, "删除过期链接: %s -> %s\n", path, linkTarget)
+            removed++
+        }
+        result.record(op)
+    }
+    return removed
 }
 
 // 智能获取剧集名和季数
@@ -490,8 +910,147 @@ func (s *SymlinkService) getSeriesInfo(sourceDir, targetDir string, videoFiles [
     absTargetDir, _ := filepath.Abs(targetDir)
     targetBasename := filepath.Base(absTargetDir)
 
-    // 检查目标路径是否已经是季数目录
-    isSeasonDir := regexp.MustCompile(`^[Ss][0-9]`).MatchString(targetBasename)
+    // 检查目标路径是否已经是季数目录（S01 或 Season 01）
+    isSeasonDir := targetSeasonDirRegex.MatchString(targetBasename)
 
     return s.determineTargetDirectory(sourceDir, targetDir, videoFiles, isSeasonDir)
-}
\ No newline at end of file
+}
+
+// Undo 撤销任务 jobID 的处理：删除创建的符号链接，将移动或重命名的文件恢复到原位置
+// 媒体库清单中记录为其他任务创建的链接和文件不撤销
+func (s *SymlinkService) Undo(ctx context.Context, jobID string, original *models.ProcessResult, onEvent EventFunc) (*models.ProcessResult, error) {
+    result := &processRecorder{onEvent: onEvent}
+    result.result.SeriesName = original.SeriesName
+    result.result.Season = original.Season
+    result.result.TargetDir = original.TargetDir
+    result.start(original.Processed + original.Removed)
+
+    // 逆序撤销，保证后执行的操作先恢复
+    for i := len(original.Operations) - 1; i >= 0; i-- {
+        op := original.Operations[i]
+        if op.Status != models.OperationDone {
+            continue
+        }
+
+        if ctx.Err() != nil {
+            var remaining []string
+            for j := i; j >= 0; j-- {
+                if original.Operations[j].Status == models.OperationDone {
+                    remaining = append(remaining, original.Operations[j].Target)
+                }
+            }
+            result.cancelRemaining(remaining, "undo")
+            return result.finish(), ErrCancelled
+        }
+
+        undo := models.FileOperation{
+            Action: "undo-" + op.Action,
+            Source: op.Target,
+            Target: op.Source,
+            Status: models.OperationDone,
+        }
+
+        var err error
+        if op.Action == "link" || op.Action == "move" {
+            if owner, ok := s.manifest.Owner(op.Target, ""); ok && owner.JobID != jobID {
+                err = fmt.Errorf("'%s' 已由任务 %s 重新创建，跳过", op.Target, owner.JobID)
+            }
+        }
+        switch {
+        case err != nil:
+        case op.Action == "link":
+            if err = s.removeLink(op.Target, op.LinkTarget); err == nil {
+                fmt.Fprintf(result, "删除链接: %s\n", op.Target)
+                // 恢复被替换的旧链接
+                if op.ReplacedTarget != "" {
+                    if err = os.Symlink(op.ReplacedTarget, op.Target); err == nil {
+                        fmt.Fprintf(result, "恢复链接: %s -> %s\n", op.Target, op.ReplacedTarget)
+                    }
+                }
+            }
+        case op.Action == "unlink":
+            if _, statErr := os.Lstat(op.Target); statErr == nil {
+                err = fmt.Errorf("'%s' 已存在，跳过", op.Target)
+            } else if err = os.Symlink(op.LinkTarget, op.Target); err == nil {
+                fmt.Fprintf(result, "恢复链接: %s -> %s\n", op.Target, op.LinkTarget)
+            }
+        default:
+            if err = s.restoreFile(ctx, op.Target, op.Source, result); err == nil {
+                fmt.Fprintf(result, "恢复文件: %s -> %s\n", op.Target, op.Source)
+            }
+        }
+
+        if err != nil {
+            fmt.Fprintf(result, "错误: %v\n", err)
+            undo.Status = models.OperationFailed
+            undo.Error = err.Error()
+        } else {
+            s.updateManifestAfterUndo(op, result)
+        }
+        result.record(undo)
+    }
+
+    if result.result.Processed > 0 {
+        fmt.Fprintf(result, "完成! 共撤销 %d 个操作\n", result.result.Processed)
+    } else {
+        result.WriteString("没有需要撤销的操作\n")
+    }
+
+    return result.finish(), nil
+}
+
+// updateManifestAfterUndo 从清单中移除已撤销的链接和文件，恢复的链接和重命名的路径重新记录到所在媒体库的清单
+func (s *SymlinkService) updateManifestAfterUndo(op models.FileOperation, result *processRecorder) {
+    var err error
+    switch op.Action {
+    case "link", "move":
+        // 移动的文件已恢复到源位置，同时清除文件上的扩展属性
+        err = s.manifest.Forget([]string{op.Target, op.Source})
+        // 恢复的旧链接由本工具创建时重新记录
+        if err == nil && op.ReplacedTarget != "" && op.Owner != "" {
+            op.Source, op.LinkTarget = "", op.ReplacedTarget
+            err = s.manifest.Record(s.manifest.Root(op.Target), op.Owner, []models.FileOperation{op})
+        }
+    case "rename":
+        // 迁移任务移动的链接和文件恢复到原路径
+        err = s.manifest.Rename(op.Target, op.Source)
+    case "unlink":
+        op.Action = "link"
+        err = s.manifest.Record(s.manifest.Root(op.Target), op.Owner, []models.FileOperation{op})
+    }
+    if err != nil {
+        fmt.Fprintf(result, "警告: 无法更新媒体库清单: %v\n", err)
+    }
+}
+
+// 删除由本工具创建的符号链接，链接已被修改时不删除
+func (s *SymlinkService) removeLink(linkPath, linkTarget string) error {
+    fileInfo, err := os.Lstat(linkPath)
+    if err != nil {
+        return fmt.Errorf("无法访问链接 '%s': %v", linkPath, err)
+    }
+    if fileInfo.Mode()&os.ModeSymlink == 0 {
+        return fmt.Errorf("'%s' 不是符号链接，跳过", linkPath)
+    }
+    if current, err := os.Readlink(linkPath); err != nil || current != linkTarget {
+        return fmt.Errorf("链接 '%s' 已被修改，跳过", linkPath)
+    }
+    if err := os.Remove(linkPath); err != nil {
+        return fmt.Errorf("无法删除链接 '%s': %v", linkPath, err)
+    }
+    return nil
+}
+
+// 将文件恢复到原位置，原位置已存在文件时不覆盖
+func (s *SymlinkService) restoreFile(ctx context.Context, current, original string, result *processRecorder) error {
+    if _, err := os.Lstat(original); err == nil {
+        return fmt.Errorf("原位置已存在文件 '%s'，跳过", original)
+    }
+    if err := s.ensureDirectoryExists(filepath.Dir(original)); err != nil {
+        return err
+    }
+    if err := s.moveFile(ctx, current, original, result); err != nil {
+        return fmt.Errorf("无法恢复 '%s': %v", current, err)
+    }
+    return nil
+}
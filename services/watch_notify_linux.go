@@ -0,0 +1,70 @@
+//go:build linux
+
+package services
+
+import (
+    "fmt"
+    "syscall"
+)
+
+// 触发重新扫描的 inotify 事件
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO |
+    syscall.IN_MOVED_FROM | syscall.IN_DELETE
+
+// inotifyNotifier 使用 inotify 监视目录变化
+type inotifyNotifier struct {
+    fd     int
+    events chan struct{}
+}
+
+// newDirNotifier 创建 inotify 监视器
+func newDirNotifier() (dirNotifier, error) {
+    fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+    if err != nil {
+        return nil, fmt.Errorf("inotify 初始化失败: %v", err)
+    }
+
+    n := &inotifyNotifier{
+        fd:     fd,
+        events: make(chan struct{}, 1),
+    }
+    go n.read()
+    return n, nil
+}
+
+// Add 监视目录（不递归）。重复添加同一目录时内核复用已有的监视，
+// 目录被删除后重新创建时需要再次添加
+func (n *inotifyNotifier) Add(path string) error {
+    if _, err := syscall.InotifyAddWatch(n.fd, path, inotifyMask); err != nil {
+        return fmt.Errorf("无法监视目录 %s: %v", path, err)
+    }
+    return nil
+}
+
+// Events 目录发生变化时发出信号，多个事件合并为一个
+func (n *inotifyNotifier) Events() <-chan struct{} {
+    return n.events
+}
+
+func (n *inotifyNotifier) Name() string {
+    return "inotify"
+}
+
+// read 读取 inotify 事件，只关心是否发生变化，不解析具体事件
+func (n *inotifyNotifier) read() {
+    buf := make([]byte, 64*1024)
+    for {
+        count, err := syscall.Read(n.fd, buf)
+        if err == syscall.EINTR {
+            continue
+        }
+        if err != nil || count <= 0 {
+            return
+        }
+
+        select {
+        case n.events <- struct{}{}:
+        default:
+        }
+    }
+}
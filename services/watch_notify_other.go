@@ -0,0 +1,10 @@
+//go:build !linux
+
+package services
+
+import "fmt"
+
+// newDirNotifier 非 Linux 系统不支持 inotify，使用轮询
+func newDirNotifier() (dirNotifier, error) {
+    return nil, fmt.Errorf("当前系统不支持 inotify")
+}
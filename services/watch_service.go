@@ -0,0 +1,402 @@
+package services
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+    "vdsymlink-web/config"
+    "vdsymlink-web/models"
+)
+
+// 监视状态文件名
+const watchStateFile = "watch_state.json"
+
+// 收到目录变化事件后等待的时间，合并短时间内的多个事件
+const watchDebounce = 2 * time.Second
+
+// 下载工具使用的临时文件后缀，目录中存在这些文件时视为尚未下载完成
+var partialSuffixes = []string{".part", ".!qb", ".!ut", ".crdownload", ".aria2", ".tmp"}
+
+// dirNotifier 目录变化通知
+type dirNotifier interface {
+    Add(path string) error
+    Events() <-chan struct{}
+    Name() string
+}
+
+// watchRule 已校验的监视规则
+type watchRule struct {
+    config.WatchRule
+    sourceRoot string // 解析后的源根目录
+    targetDir  string // 解析后的目标目录
+    stable     time.Duration
+    user       *User
+}
+
+// watchFolder 剧集目录最近一次扫描的文件签名
+type watchFolder struct {
+    signature string
+    changedAt time.Time
+}
+
+// watchState 持久化的监视状态，重启后不会重复处理
+type watchState struct {
+    Rules   []string          `json:"rules"`   // 已完成首次扫描的规则
+    Folders map[string]string `json:"folders"` // 剧集目录 -> 已处理时的文件签名
+}
+
+// WatchService 监视下载目录，剧集目录中的文件停止增长后自动加入处理队列
+type WatchService struct {
+    jobs      *JobManager
+    rules     []*watchRule
+    poll      time.Duration
+    notifier  dirNotifier
+    statePath string
+    state     watchState
+    folders   map[string]*watchFolder
+}
+
+func NewWatchService(cfg config.WatchConfig, guard *PathGuard, mapper *PathMapper, jobs *JobManager, dataDir string) (*WatchService, error) {
+    w := &WatchService{
+        jobs:      jobs,
+        poll:      time.Duration(cfg.PollSeconds) * time.Second,
+        statePath: filepath.Join(dataDir, watchStateFile),
+        folders:   make(map[string]*watchFolder),
+    }
+    if w.poll <= 0 {
+        w.poll = time.Minute
+    }
+
+    names := make(map[string]bool)
+    for _, rc := range cfg.Rules {
+        rule, err := newWatchRule(rc, cfg.StableSeconds, guard, mapper)
+        if err != nil {
+            return nil, err
+        }
+        if names[rule.Name] {
+            return nil, fmt.Errorf("监视规则名称重复: %s", rule.Name)
+        }
+        names[rule.Name] = true
+        w.rules = append(w.rules, rule)
+    }
+
+    if err := w.loadState(); err != nil {
+        return nil, err
+    }
+
+    if !cfg.Polling {
+        notifier, err := newDirNotifier()
+        if err != nil {
+            fmt.Printf("⚠️ %v，使用轮询监视目录\n", err)
+        } else {
+            w.notifier = notifier
+        }
+    }
+    return w, nil
+}
+
+// newWatchRule 校验监视规则，路径必须位于允许的根目录下
+func newWatchRule(rc config.WatchRule, stableSeconds int, guard *PathGuard, mapper *PathMapper) (*watchRule, error) {
+    if rc.Name == "" {
+        return nil, fmt.Errorf("监视规则缺少名称")
+    }
+    if rc.Mode == "" {
+        rc.Mode = "link"
+    }
+    if rc.Mode != "link" && rc.Mode != "move" {
+        return nil, fmt.Errorf("监视规则 %s 的模式必须为 link 或 move", rc.Name)
+    }
+    if rc.SourceRoot == "" || rc.TargetDir == "" {
+        return nil, fmt.Errorf("监视规则 %s 需要填写 sourceRoot 和 targetDir", rc.Name)
+    }
+    if rc.Sync && rc.Mode != "link" {
+        return nil, fmt.Errorf("监视规则 %s: 同步仅支持 link 模式", rc.Name)
+    }
+    if err := mapper.Validate(rc.PathMap, rc.RedirectPath); err != nil {
+        return nil, fmt.Errorf("监视规则 %s: %v", rc.Name, err)
+    }
+    if _, err := LookupNaming(rc.Naming); err != nil {
+        return nil, fmt.Errorf("监视规则 %s: %v", rc.Name, err)
+    }
+
+    sourceRoot, err := guard.Resolve(rc.SourceRoot, ScopeSource)
+    if err != nil {
+        return nil, fmt.Errorf("监视规则 %s: %v", rc.Name, err)
+    }
+    targetDir, err := guard.Resolve(rc.TargetDir, ScopeTarget)
+    if err != nil {
+        return nil, fmt.Errorf("监视规则 %s: %v", rc.Name, err)
+    }
+
+    if rc.StableSeconds > 0 {
+        stableSeconds = rc.StableSeconds
+    }
+    return &watchRule{
+        WatchRule:  rc,
+        sourceRoot: sourceRoot,
+        targetDir:  targetDir,
+        stable:     time.Duration(stableSeconds) * time.Second,
+        user:       &User{Username: "watch:" + rc.Name, Method: "watch"},
+    }, nil
+}
+
+// Method 监视方式：inotify 或 polling
+func (w *WatchService) Method() string {
+    if w.notifier == nil {
+        return "polling"
+    }
+    return w.notifier.Name()
+}
+
+// Run 持续监视直到 ctx 取消
+func (w *WatchService) Run(ctx context.Context) {
+    var events <-chan struct{}
+    if w.notifier != nil {
+        events = w.notifier.Events()
+    }
+
+    timer := time.NewTimer(0)
+    defer timer.Stop()
+    nextScan := time.Now()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-events:
+            // 事件通常成批到达，稍等片刻后再扫描
+            if time.Until(nextScan) > watchDebounce {
+                nextScan = time.Now().Add(watchDebounce)
+                timer.Reset(watchDebounce)
+            }
+            continue
+        case <-timer.C:
+        }
+
+        wait := w.scan(time.Now())
+        nextScan = time.Now().Add(wait)
+        timer.Reset(wait)
+    }
+}
+
+// scan 扫描所有规则，返回距离下一次扫描的时间
+func (w *WatchService) scan(now time.Time) time.Duration {
+    next := w.poll
+    changed := false
+    scanned := make(map[string]bool) // 成功扫描的源根目录
+    seen := make(map[string]bool)    // 仍然存在且包含视频的剧集目录
+
+    for _, rule := range w.rules {
+        entries, err := os.ReadDir(rule.sourceRoot)
+        if err != nil {
+            fmt.Printf("⚠️ 监视规则 %s 无法读取目录: %v\n", rule.Name, err)
+            continue
+        }
+        scanned[rule.sourceRoot] = true
+        w.addWatch(rule.sourceRoot)
+        first := !w.state.hasRule(rule.Name)
+
+        for _, entry := range entries {
+            if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+                continue
+            }
+            dir := filepath.Join(rule.sourceRoot, entry.Name())
+            w.addWatch(dir)
+
+            signature, complete := folderSignature(dir)
+            if signature == "" {
+                continue
+            }
+            seen[dir] = true
+
+            // 文件有变化或仍在下载时重新计时
+            folder := w.folders[dir]
+            if folder == nil {
+                folder = &watchFolder{}
+                w.folders[dir] = folder
+            }
+            if folder.signature != signature || !complete {
+                folder.signature = signature
+                folder.changedAt = now
+            }
+
+            // 首次扫描时已存在的目录只记录，不处理
+            if first && !rule.ProcessExisting {
+                w.state.Folders[dir] = signature
+                changed = true
+                continue
+            }
+            if w.state.Folders[dir] == signature {
+                continue
+            }
+            if wait := rule.stable - now.Sub(folder.changedAt); wait > 0 {
+                if wait < next {
+                    next = wait
+                }
+                continue
+            }
+
+            if w.enqueue(rule, dir) {
+                w.state.Folders[dir] = signature
+                changed = true
+            }
+        }
+
+        if first {
+            w.state.Rules = append(w.state.Rules, rule.Name)
+            changed = true
+        }
+    }
+
+    // 清理已删除或已不包含视频的目录
+    for dir := range w.folders {
+        if scanned[filepath.Dir(dir)] && !seen[dir] {
+            delete(w.folders, dir)
+        }
+    }
+    for dir := range w.state.Folders {
+        if scanned[filepath.Dir(dir)] && !seen[dir] {
+            delete(w.state.Folders, dir)
+            changed = true
+        }
+    }
+
+    if changed {
+        if err := w.saveState(); err != nil {
+            fmt.Printf("⚠️ 保存监视状态失败: %v\n", err)
+        }
+    }
+    if next < time.Second {
+        next = time.Second
+    }
+    return next
+}
+
+// enqueue 将下载完成的剧集目录加入处理队列，队列已满时下次扫描重试
+func (w *WatchService) enqueue(rule *watchRule, dir string) bool {
+    req := models.ProcessRequest{
+        SourceDir:    dir,
+        TargetDir:    rule.TargetDir,
+        Mode:         rule.Mode,
+        RedirectPath: rule.RedirectPath,
+        PathMap:      rule.PathMap,
+        Sync:         rule.Sync,
+        Naming:       rule.Naming,
+        Async:        true,
+    }
+    opts := ProcessOptions{
+        SourceDir:    dir,
+        TargetDir:    rule.targetDir,
+        Mode:         rule.Mode,
+        RedirectPath: rule.RedirectPath,
+        PathMap:      rule.PathMap,
+        Sync:         rule.Sync,
+        Naming:       rule.Naming,
+    }
+
+    job, err := w.jobs.Enqueue(req, rule.user, opts)
+    if err != nil {
+        fmt.Printf("⚠️ 监视规则 %s 无法处理 %s: %v\n", rule.Name, dir, err)
+        return false
+    }
+    fmt.Printf("👀 监视规则 %s 检测到下载完成: %s -> 任务 %s\n", rule.Name, dir, job.ID)
+    return true
+}
+
+// addWatch 使用 inotify 时监视目录
+func (w *WatchService) addWatch(dir string) {
+    if w.notifier == nil {
+        return
+    }
+    if err := w.notifier.Add(dir); err != nil {
+        fmt.Printf("⚠️ %v\n", err)
+    }
+}
+
+// folderSignature 目录中视频文件的名称、大小和修改时间的摘要，没有视频文件时返回空字符串
+// complete 为 false 表示目录中还有下载中的临时文件
+func folderSignature(dir string) (signature string, complete bool) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return "", false
+    }
+
+    complete = true
+    hash := sha256.New()
+    videos := 0
+    for _, entry := range entries {
+        if entry.IsDir() {
+            continue
+        }
+        name := entry.Name()
+        lower := strings.ToLower(name)
+        for _, suffix := range partialSuffixes {
+            if strings.HasSuffix(lower, suffix) {
+                complete = false
+            }
+        }
+        if !isVideoFile(name) {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil {
+            continue
+        }
+        videos++
+        fmt.Fprintf(hash, "%s|%d|%d\n", name, info.Size(), info.ModTime().UnixNano())
+    }
+
+    if videos == 0 {
+        return "", complete
+    }
+    return hex.EncodeToString(hash.Sum(nil)), complete
+}
+
+// hasRule 规则是否已完成首次扫描
+func (s *watchState) hasRule(name string) bool {
+    for _, rule := range s.Rules {
+        if rule == name {
+            return true
+        }
+    }
+    return false
+}
+
+// loadState 读取监视状态
+func (w *WatchService) loadState() error {
+    w.state.Folders = make(map[string]string)
+
+    data, err := os.ReadFile(w.statePath)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("无法读取监视状态: %v", err)
+    }
+    if err := json.Unmarshal(data, &w.state); err != nil {
+        return fmt.Errorf("监视状态文件格式错误: %v", err)
+    }
+    if w.state.Folders == nil {
+        w.state.Folders = make(map[string]string)
+    }
+    return nil
+}
+
+// saveState 保存监视状态，先写临时文件再重命名，避免写入中断损坏文件
+func (w *WatchService) saveState() error {
+    data, err := json.MarshalIndent(w.state, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := w.statePath + ".tmp"
+    if err := os.WriteFile(tmp, data, 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, w.statePath)
+}
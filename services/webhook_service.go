@@ -0,0 +1,534 @@
+package services
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "text/template"
+    "time"
+    "vdsymlink-web/config"
+    "vdsymlink-web/models"
+)
+
+// 通知发送记录文件名和内存中保留的记录数
+const (
+    webhookDeliveryFile   = "webhook_deliveries.jsonl"
+    maxWebhookDeliveries  = 500
+    webhookAttempts       = 3
+    webhookResponseLength = 1024
+)
+
+// 签名请求头：X-VD-Signature: sha256=<请求体的 HMAC-SHA256 十六进制>
+const (
+    webhookEventHeader     = "X-VD-Event"
+    webhookDeliveryHeader  = "X-VD-Delivery"
+    webhookSignatureHeader = "X-VD-Signature"
+)
+
+// 使用预设时的默认消息文本
+const defaultWebhookMessage = `{{eventName .Event}}: {{if .Series}}{{.Series}}{{if .Season}} S{{.Season}}{{end}}{{else}}{{.SourceDir}}{{end}}
+{{- if eq .Event "started"}}
+模式: {{.Mode}}
+{{- else}}
+成功 {{.Processed}} 个，失败 {{.Failed}} 个
+{{- range .Files}}
++ {{.}}
+{{- end}}
+{{- range .Errors}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- if .Error}}
+错误: {{.Error}}
+{{- end}}
+{{- if .JobURL}}
+{{.JobURL}}
+{{- end}}`
+
+// webhookPresets 聊天机器人的消息格式
+var webhookPresets = map[string]func(text string) any{
+    "discord":  func(text string) any { return map[string]any{"content": text} },
+    "slack":    func(text string) any { return map[string]any{"text": text} },
+    "telegram": func(text string) any { return map[string]any{"text": text} },
+    "feishu": func(text string) any {
+        return map[string]any{"msg_type": "text", "content": map[string]any{"text": text}}
+    },
+    "dingtalk": func(text string) any {
+        return map[string]any{"msgtype": "text", "text": map[string]any{"content": text}}
+    },
+    "wecom": func(text string) any {
+        return map[string]any{"msgtype": "text", "text": map[string]any{"content": text}}
+    },
+}
+
+var webhookEventNames = map[string]string{
+    models.WebhookStarted:   "任务开始",
+    models.WebhookSucceeded: "任务完成",
+    models.WebhookPartial:   "任务部分失败",
+    models.WebhookFailed:    "任务失败",
+    models.WebhookCancelled: "任务已取消",
+}
+
+var webhookFuncs = template.FuncMap{
+    "eventName": func(event string) string { return webhookEventNames[event] },
+    "join":      strings.Join,
+    "json": func(v any) (string, error) {
+        data, err := json.Marshal(v)
+        return string(data), err
+    },
+}
+
+// webhook 已解析模板的通知配置
+type webhook struct {
+    config.WebhookConfig
+    events   map[string]bool
+    template *template.Template
+    preset   func(text string) any
+}
+
+// WebhookService 将任务事件发送到配置的 HTTP 地址，并记录每次发送
+type WebhookService struct {
+    webhooks  []*webhook
+    publicURL string
+    client    *http.Client
+    backoff   time.Duration
+
+    mu         sync.RWMutex
+    path       string
+    file       *os.File
+    deliveries []*models.WebhookDelivery
+
+    queueMu sync.Mutex
+    queues  map[string][]queuedWebhook // 任务ID -> 待发送的事件，存在时该任务有正在发送的协程
+}
+
+// queuedWebhook 等待发送的任务事件
+type queuedWebhook struct {
+    event string
+    job   *models.Job
+}
+
+func NewWebhookService(webhooks []config.WebhookConfig, publicURL, dataDir string) (*WebhookService, error) {
+    s := &WebhookService{
+        publicURL: strings.TrimRight(publicURL, "/"),
+        client:    &http.Client{Timeout: 30 * time.Second},
+        backoff:   notifyBackoff,
+        path:      filepath.Join(dataDir, webhookDeliveryFile),
+        queues:    make(map[string][]queuedWebhook),
+    }
+
+    for i, cfg := range webhooks {
+        hook, err := newWebhook(cfg, i)
+        if err != nil {
+            return nil, err
+        }
+        s.webhooks = append(s.webhooks, hook)
+    }
+
+    if err := s.load(); err != nil {
+        return nil, err
+    }
+    file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+    if err != nil {
+        return nil, fmt.Errorf("无法打开通知记录文件: %v", err)
+    }
+    s.file = file
+    return s, nil
+}
+
+// newWebhook 校验通知配置并解析模板
+func newWebhook(cfg config.WebhookConfig, index int) (*webhook, error) {
+    if cfg.Name == "" {
+        cfg.Name = fmt.Sprintf("webhook-%d", index+1)
+    }
+    if cfg.URL == "" {
+        return nil, fmt.Errorf("通知 %s 需要填写 url", cfg.Name)
+    }
+    if cfg.ContentType == "" {
+        cfg.ContentType = "application/json"
+    }
+
+    hook := &webhook{WebhookConfig: cfg, events: make(map[string]bool)}
+    for _, event := range cfg.Events {
+        if _, ok := webhookEventNames[event]; !ok {
+            return nil, fmt.Errorf("通知 %s 包含不支持的事件: %s", cfg.Name, event)
+        }
+        hook.events[event] = true
+    }
+
+    text := cfg.Template
+    if cfg.Preset != "" {
+        preset, ok := webhookPresets[strings.ToLower(cfg.Preset)]
+        if !ok {
+            return nil, fmt.Errorf("通知 %s 的预设 %s 不存在", cfg.Name, cfg.Preset)
+        }
+        hook.preset = preset
+        if text == "" {
+            text = defaultWebhookMessage
+        }
+    }
+    if text != "" {
+        tmpl, err := template.New(cfg.Name).Funcs(webhookFuncs).Parse(text)
+        if err != nil {
+            return nil, fmt.Errorf("通知 %s 的模板格式错误: %v", cfg.Name, err)
+        }
+        hook.template = tmpl
+    }
+    return hook, nil
+}
+
+// Enabled 是否配置了通知
+func (s *WebhookService) Enabled() bool {
+    return s != nil && len(s.webhooks) > 0
+}
+
+// webhookEvent 根据任务状态返回通知事件，不需要通知时返回空字符串
+func webhookEvent(job *models.Job) string {
+    switch job.Status {
+    case models.JobRunning:
+        return models.WebhookStarted
+    case models.JobSucceeded:
+        if job.Result != nil && job.Result.Failed > 0 {
+            return models.WebhookPartial
+        }
+        return models.WebhookSucceeded
+    case models.JobFailed:
+        return models.WebhookFailed
+    case models.JobCancelled:
+        return models.WebhookCancelled
+    }
+    return ""
+}
+
+// Enqueue 在后台发送任务事件，同一任务的事件按加入顺序依次发送（包括重试），
+// 不同任务的事件互不等待
+func (s *WebhookService) Enqueue(event string, job *models.Job) {
+    s.queueMu.Lock()
+    queued, sending := s.queues[job.ID]
+    s.queues[job.ID] = append(queued, queuedWebhook{event: event, job: job})
+    s.queueMu.Unlock()
+    if !sending {
+        go s.drain(job.ID)
+    }
+}
+
+// drain 依次发送任务的待发送事件，队列为空时退出
+func (s *WebhookService) drain(jobID string) {
+    for {
+        s.queueMu.Lock()
+        queued := s.queues[jobID]
+        if len(queued) == 0 {
+            delete(s.queues, jobID)
+            s.queueMu.Unlock()
+            return
+        }
+        next := queued[0]
+        s.queues[jobID] = queued[1:]
+        s.queueMu.Unlock()
+
+        s.Dispatch(next.event, next.job)
+    }
+}
+
+// Dispatch 发送任务事件到订阅了该事件的所有通知地址
+func (s *WebhookService) Dispatch(event string, job *models.Job) {
+    payload := s.payload(event, job)
+    for _, hook := range s.webhooks {
+        if len(hook.events) > 0 && !hook.events[event] {
+            continue
+        }
+
+        delivery := &models.WebhookDelivery{
+            ID:          NewJobID(),
+            Webhook:     hook.Name,
+            Event:       event,
+            JobID:       job.ID,
+            URL:         hook.URL,
+            ContentType: hook.ContentType,
+        }
+        body, err := hook.render(payload)
+        if err != nil {
+            delivery.Error = "生成通知内容失败: " + err.Error()
+            delivery.At = time.Now()
+            s.record(delivery)
+            continue
+        }
+        delivery.Body = body
+        s.send(context.Background(), hook, delivery)
+        s.record(delivery)
+    }
+}
+
+// Resend 手动重新发送一条通知记录，生成新的记录
+func (s *WebhookService) Resend(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+    original, ok := s.Delivery(id)
+    if !ok {
+        return nil, fmt.Errorf("通知记录不存在")
+    }
+
+    var hook *webhook
+    for _, h := range s.webhooks {
+        if h.Name == original.Webhook {
+            hook = h
+        }
+    }
+    if hook == nil {
+        return nil, fmt.Errorf("通知 %s 已不在配置中", original.Webhook)
+    }
+    if original.Body == "" {
+        return nil, fmt.Errorf("通知记录没有可发送的内容")
+    }
+
+    delivery := &models.WebhookDelivery{
+        ID:          NewJobID(),
+        Webhook:     hook.Name,
+        Event:       original.Event,
+        JobID:       original.JobID,
+        URL:         hook.URL,
+        ContentType: hook.ContentType,
+        Body:        original.Body,
+        ResendOf:    original.ID,
+    }
+    s.send(ctx, hook, delivery)
+    s.record(delivery)
+    return delivery, nil
+}
+
+// payload 从任务生成模板数据
+func (s *WebhookService) payload(event string, job *models.Job) models.WebhookPayload {
+    payload := models.WebhookPayload{
+        Event:      event,
+        JobID:      job.ID,
+        Status:     job.Status,
+        Mode:       job.Request.Mode,
+        User:       job.User,
+        SourceDir:  job.Request.SourceDir,
+        TargetDir:  job.Request.TargetDir,
+        Error:      job.Error,
+        StartedAt:  job.StartedAt,
+        FinishedAt: job.FinishedAt,
+    }
+    if s.publicURL != "" {
+        payload.JobURL = s.publicURL + "/jobs/" + job.ID
+    }
+
+    if result := job.Result; result != nil {
+        payload.Series = result.SeriesName
+        payload.Season = result.Season
+        payload.TargetDir = result.TargetDir
+        payload.Processed = result.Processed
+        payload.Failed = result.Failed
+        payload.Removed = result.Removed
+        for _, op := range result.Operations {
+            switch op.Status {
+            case models.OperationDone:
+                if op.Action == "unlink" {
+                    continue
+                }
+                payload.Files = append(payload.Files, filepath.Base(op.Target))
+            case models.OperationSkipped:
+                payload.Skipped++
+            case models.OperationFailed:
+                payload.Errors = append(payload.Errors, fmt.Sprintf("%s: %s", filepath.Base(op.Source), op.Error))
+            }
+        }
+    }
+    return payload
+}
+
+// render 生成请求体：未配置模板时发送完整的 JSON 数据
+func (h *webhook) render(payload models.WebhookPayload) (string, error) {
+    if h.template == nil {
+        data, err := json.Marshal(payload)
+        return string(data), err
+    }
+
+    var text bytes.Buffer
+    if err := h.template.Execute(&text, payload); err != nil {
+        return "", err
+    }
+    if h.preset == nil {
+        return text.String(), nil
+    }
+    data, err := json.Marshal(h.preset(text.String()))
+    return string(data), err
+}
+
+// send 发送通知，失败时按指数退避重试
+func (s *WebhookService) send(ctx context.Context, hook *webhook, delivery *models.WebhookDelivery) {
+    backoff := s.backoff
+    for attempt := 0; attempt < webhookAttempts; attempt++ {
+        if attempt > 0 {
+            select {
+            case <-time.After(backoff):
+            case <-ctx.Done():
+            }
+            backoff *= 2
+        }
+        if ctx.Err() != nil {
+            break
+        }
+
+        delivery.Attempts++
+        err := s.post(ctx, hook, delivery)
+        if err == nil {
+            delivery.Success = true
+            delivery.Error = ""
+            break
+        }
+        delivery.Error = err.Error()
+    }
+    delivery.At = time.Now()
+
+    if !delivery.Success {
+        fmt.Printf("⚠️ 通知 %s 发送失败（%d 次尝试）: %s\n", hook.Name, delivery.Attempts, delivery.Error)
+    }
+}
+
+// post 发送一次请求，非 2xx 响应视为失败
+func (s *WebhookService) post(ctx context.Context, hook *webhook, delivery *models.WebhookDelivery) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(delivery.Body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", hook.ContentType)
+    req.Header.Set(webhookEventHeader, delivery.Event)
+    req.Header.Set(webhookDeliveryHeader, delivery.ID)
+    if hook.Secret != "" {
+        req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(hook.Secret, delivery.Body))
+    }
+    for key, value := range hook.Headers {
+        req.Header.Set(key, value)
+    }
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLength))
+    delivery.StatusCode = resp.StatusCode
+    delivery.Response = string(body)
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return fmt.Errorf("HTTP %d", resp.StatusCode)
+    }
+    return nil
+}
+
+// signWebhook 计算请求体的 HMAC-SHA256 签名
+func signWebhook(secret, body string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(body))
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliveries 按时间倒序列出发送记录，webhook 为空时不过滤
+func (s *WebhookService) Deliveries(webhook string, limit int) []*models.WebhookDelivery {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    deliveries := []*models.WebhookDelivery{}
+    for i := len(s.deliveries) - 1; i >= 0; i-- {
+        delivery := s.deliveries[i]
+        if webhook != "" && delivery.Webhook != webhook {
+            continue
+        }
+        copied := *delivery
+        deliveries = append(deliveries, &copied)
+        if limit > 0 && len(deliveries) >= limit {
+            break
+        }
+    }
+    return deliveries
+}
+
+// Delivery 获取发送记录
+func (s *WebhookService) Delivery(id string) (*models.WebhookDelivery, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    for _, delivery := range s.deliveries {
+        if delivery.ID == id {
+            copied := *delivery
+            return &copied, true
+        }
+    }
+    return nil, false
+}
+
+// record 保存发送记录
+func (s *WebhookService) record(delivery *models.WebhookDelivery) {
+    data, err := json.Marshal(delivery)
+    if err != nil {
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.deliveries = append(s.deliveries, delivery)
+    if len(s.deliveries) > maxWebhookDeliveries {
+        s.deliveries = s.deliveries[len(s.deliveries)-maxWebhookDeliveries:]
+    }
+    if _, err := s.file.Write(append(data, '\n')); err != nil {
+        fmt.Printf("⚠️ 保存通知记录失败: %v\n", err)
+    }
+}
+
+// load 读取最近的发送记录，记录过多时重写文件
+func (s *WebhookService) load() error {
+    file, err := os.Open(s.path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("无法读取通知记录: %v", err)
+    }
+
+    lines := 0
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+    for scanner.Scan() {
+        var delivery models.WebhookDelivery
+        if err := json.Unmarshal(scanner.Bytes(), &delivery); err != nil || delivery.ID == "" {
+            continue
+        }
+        lines++
+        s.deliveries = append(s.deliveries, &delivery)
+        if len(s.deliveries) > maxWebhookDeliveries {
+            s.deliveries = s.deliveries[1:]
+        }
+    }
+    file.Close()
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("无法读取通知记录: %v", err)
+    }
+
+    if lines <= 2*maxWebhookDeliveries {
+        return nil
+    }
+    var buf bytes.Buffer
+    encoder := json.NewEncoder(&buf)
+    for _, delivery := range s.deliveries {
+        if err := encoder.Encode(delivery); err != nil {
+            return err
+        }
+    }
+    tmp := s.path + ".tmp"
+    if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+        return fmt.Errorf("无法压缩通知记录: %v", err)
+    }
+    return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+    "vdsymlink-web/config"
+    "vdsymlink-web/models"
+)
+
+func TestWebhookEventsOfAJobArriveInOrder(t *testing.T) {
+    var mu sync.Mutex
+    var events []string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var payload models.WebhookPayload
+        json.NewDecoder(r.Body).Decode(&payload)
+        // 开始事件发送较慢，之后的事件仍需等待其发送完成
+        if payload.Event == models.WebhookStarted {
+            time.Sleep(100 * time.Millisecond)
+        }
+        mu.Lock()
+        events = append(events, payload.Event)
+        mu.Unlock()
+    }))
+    defer server.Close()
+
+    webhooks, err := NewWebhookService([]config.WebhookConfig{{Name: "test", URL: server.URL}}, "", t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    job := &models.Job{ID: "job", Status: models.JobRunning}
+    webhooks.Enqueue(webhookEvent(job), job)
+    finished := *job
+    finished.Status = models.JobSucceeded
+    webhooks.Enqueue(webhookEvent(&finished), &finished)
+
+    deadline := time.Now().Add(5 * time.Second)
+    for time.Now().Before(deadline) {
+        if len(webhooks.Deliveries("", 0)) == 2 {
+            break
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    if len(events) != 2 || events[0] != models.WebhookStarted || events[1] != models.WebhookSucceeded {
+        t.Fatalf("通知顺序: %v", events)
+    }
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package services
+
+import (
+    "errors"
+    "syscall"
+)
+
+// setXattr 写入文件的扩展属性（跟随符号链接）
+func setXattr(path, name, value string) error {
+    return syscall.Setxattr(path, name, []byte(value), 0)
+}
+
+// getXattr 读取文件的扩展属性，属性不存在时返回空字符串
+func getXattr(path, name string) (string, error) {
+    buf := make([]byte, 4096)
+    n, err := syscall.Getxattr(path, name, buf)
+    if errors.Is(err, syscall.ENODATA) {
+        return "", nil
+    }
+    if err != nil {
+        return "", err
+    }
+    return string(buf[:n]), nil
+}
+
+// removeXattr 删除文件的扩展属性，属性不存在时忽略
+func removeXattr(path, name string) error {
+    if err := syscall.Removexattr(path, name); err != nil && !errors.Is(err, syscall.ENODATA) {
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package services
+
+import "fmt"
+
+// setXattr 非 Linux 系统不写入扩展属性
+func setXattr(path, name, value string) error {
+    return fmt.Errorf("当前系统不支持扩展属性")
+}
+
+// getXattr 非 Linux 系统没有扩展属性
+func getXattr(path, name string) (string, error) {
+    return "", nil
+}
+
+// removeXattr 非 Linux 系统没有扩展属性
+func removeXattr(path, name string) error {
+    return nil
+}
@@ -1,7 +1,8 @@
 class DirectoryBrowser {
-    constructor(inputId, browseBtnId) {
+    constructor(inputId, browseBtnId, scope = '') {
         this.input = document.getElementById(inputId);
         this.browseBtn = document.getElementById(browseBtnId);
+        this.scope = scope;
         this.dropdown = null;
         this.currentPath = '';
         this.autoBrowseEnabled = true;
@@ -200,13 +201,14 @@ class DirectoryBrowser {
         try {
             const params = new URLSearchParams();
             if (path) params.append('path', path);
+            if (this.scope) params.append('scope', this.scope);
 
             const response = await fetch(`/api/directories?${params}`);
             const data = await response.json();
 
             // 无论成功还是失败，都更新当前路径
-            this.currentPath = data.currentPath || path;
-            currentPathSpan.textContent = this.currentPath === '/' ? '/' : this.currentPath + '/';
+            this.currentPath = data.currentPath !== undefined ? data.currentPath : path;
+            currentPathSpan.textContent = this.formatPath(this.currentPath);
 
             const fragment = document.createDocumentFragment();
 
@@ -216,7 +218,14 @@ class DirectoryBrowser {
                     const dirElement = document.createElement('div');
                     dirElement.className = 'directory-item';
 
-                    if (dir.type === 'parent') {
+                    if (dir.type === 'root') {
+                        dirElement.className += ' root-directory';
+                        dirElement.innerHTML = `
+                            <span class="dir-icon">🗂</span>
+                            <span class="dir-name">${this.escapeHtml(dir.name)}</span>
+                            <span class="dir-desc">（根目录）</span>
+                        `;
+                    } else if (dir.type === 'parent') {
                         dirElement.className += ' parent-directory';
                         dirElement.innerHTML = `
                             <span class="dir-icon">↶</span>
@@ -224,6 +233,7 @@ class DirectoryBrowser {
                             <span class="dir-desc">（上级目录）</span>
                         `;
                     } else {
+                        dirElement.dataset.name = dir.name;
                         dirElement.innerHTML = `
                             <span class="dir-icon">📁</span>
                             <span class="dir-name">${this.escapeHtml(dir.name)}</span>
@@ -232,7 +242,10 @@ class DirectoryBrowser {
 
                     dirElement.addEventListener('click', (e) => {
                         e.stopPropagation();
-                        this.input.value = dir.path === '/' ? '/' : dir.path + '/';
+                        // 返回根目录列表时不修改输入框
+                        if (dir.path) {
+                            this.input.value = this.formatPath(dir.path);
+                        }
                         this.input.focus();
                         this.loadDirectories(dir.path);
                     });
@@ -254,11 +267,15 @@ class DirectoryBrowser {
                 directoryList.innerHTML = '<div class="empty">该目录下没有子目录</div>';
             } else {
                 directoryList.appendChild(fragment);
+                // 源目录显示各子目录在媒体库中被链接的次数
+                if (this.scope === 'source' && this.currentPath) {
+                    this.loadReferenceCounts(this.currentPath, directoryList);
+                }
             }
 
         } catch (error) {
             this.currentPath = path;
-            currentPathSpan.textContent = this.currentPath === '/' ? '/' : this.currentPath + '/';
+            currentPathSpan.textContent = this.formatPath(this.currentPath);
             directoryList.innerHTML = `<div class="error">加载失败: ${error.message}</div>`;
         } finally {
             loadingElement.style.display = 'none';
@@ -266,6 +283,32 @@ class DirectoryBrowser {
         }
     }
 
+    async loadReferenceCounts(path, directoryList) {
+        try {
+            const response = await fetch('/api/library/references/counts?dir=' + encodeURIComponent(path));
+            const data = await response.json();
+            // 加载期间已切换到其他目录时忽略
+            if (!data.success || !data.counts || this.currentPath !== path) return;
+
+            directoryList.querySelectorAll('.directory-item[data-name]').forEach(item => {
+                const count = data.counts[item.dataset.name];
+                if (!count) return;
+                const badge = document.createElement('span');
+                badge.className = 'link-badge';
+                badge.textContent = `已链接 ${count} 次`;
+                badge.title = '媒体库中引用该目录中文件的链接数';
+                item.appendChild(badge);
+            });
+        } catch (error) {
+            // 标记只是提示，失败时不影响目录浏览
+        }
+    }
+
+    formatPath(path) {
+        if (!path) return '';
+        return path === '/' ? '/' : path + '/';
+    }
+
     escapeHtml(unsafe) {
         return unsafe
             .replace(/&/g, "&amp;")
@@ -276,19 +319,24 @@ class DirectoryBrowser {
     }
 }
 
-// 表单提交处理 - 使用 AJAX
+// 表单提交处理 - 使用 AJAX 异步提交并实时显示进度
 function handleFormSubmit(event) {
     event.preventDefault(); // 阻止默认的表单提交行为
 
-    const form = document.getElementById('mainForm');
-
     // 收集表单数据
     const formData = {
         sourceDir: document.getElementById('sourceDir').value,
         targetDir: document.getElementById('targetDir').value,
         mode: document.querySelector('input[name="mode"]:checked').value,
-        redirectPath: document.getElementById('redirectPath').value
+        redirectPath: document.getElementById('redirectPath').value,
+        strictLinks: document.getElementById('strictLinks').checked,
+        sync: document.getElementById('sync').checked,
+        async: true
     };
+    const pathMap = document.getElementById('pathMap');
+    if (pathMap) {
+        formData.pathMap = pathMap.value;
+    }
 
     // 使用 JSON 格式提交
     fetch('/api/process', {
@@ -300,8 +348,12 @@ function handleFormSubmit(event) {
     })
     .then(response => response.json())
     .then(data => {
-        // 显示处理结果
-        showProcessResult(data);
+        if (data.success && data.jobId) {
+            // 任务已加入队列，订阅实时进度
+            new JobProgressView(data.jobId).start();
+        } else {
+            showProcessResult(data);
+        }
     })
     .catch(error => {
         // 显示错误信息
@@ -314,39 +366,176 @@ function handleFormSubmit(event) {
     return false;
 }
 
-// 显示处理结果
-function showProcessResult(data) {
-    // 移除之前的结果
+// 任务实时进度视图：通过 SSE 接收日志和进度，支持取消任务
+class JobProgressView {
+    constructor(jobId) {
+        this.jobId = jobId;
+        this.source = null;
+        this.container = createResultContainer('result-container running');
+        this.container.innerHTML = `
+            <h3>处理中...</h3>
+            <div class="job-progress">
+                <div class="progress-bar"><div class="progress-fill"></div></div>
+                <span class="progress-text">排队中</span>
+            </div>
+            <div class="byte-progress"></div>
+            <pre class="job-log"></pre>
+            <div class="job-actions">
+                <button type="button" class="danger-button cancel-job">取消任务</button>
+                <a class="job-link" href="/jobs/${encodeURIComponent(jobId)}">查看任务详情</a>
+            </div>
+        `;
+        this.title = this.container.querySelector('h3');
+        this.log = this.container.querySelector('.job-log');
+        this.fill = this.container.querySelector('.progress-fill');
+        this.progressText = this.container.querySelector('.progress-text');
+        this.byteProgress = this.container.querySelector('.byte-progress');
+        this.cancelButton = this.container.querySelector('.cancel-job');
+        this.cancelButton.addEventListener('click', () => this.cancel());
+    }
+
+    start() {
+        this.source = new EventSource(`/api/jobs/${encodeURIComponent(this.jobId)}/events`);
+
+        this.source.addEventListener('log', (e) => {
+            this.appendLog(JSON.parse(e.data).message);
+        });
+
+        this.source.addEventListener('progress', (e) => {
+            this.updateProgress(JSON.parse(e.data).progress);
+        });
+
+        this.source.addEventListener('bytes', (e) => {
+            const bytes = JSON.parse(e.data).bytes;
+            const percent = bytes.total > 0 ? Math.floor(bytes.copied * 100 / bytes.total) : 100;
+            this.byteProgress.textContent = `复制 ${bytes.file}: ${formatBytes(bytes.copied)} / ${formatBytes(bytes.total)} (${percent}%)`;
+        });
+
+        this.source.addEventListener('status', (e) => {
+            const job = JSON.parse(e.data).job;
+            this.updateStatus(job);
+        });
+
+        this.source.onerror = () => {
+            // 连接断开时浏览器会自动重连，任务已结束时由 status 事件关闭连接
+            if (this.source.readyState === EventSource.CLOSED) {
+                this.title.textContent = '连接已断开';
+            }
+        };
+    }
+
+    appendLog(message) {
+        this.log.textContent += message;
+        this.log.scrollTop = this.log.scrollHeight;
+    }
+
+    updateProgress(progress) {
+        const percent = progress.total > 0 ? Math.floor(progress.completed * 100 / progress.total) : 0;
+        this.fill.style.width = percent + '%';
+        this.progressText.textContent = `${progress.completed} / ${progress.total}` +
+            (progress.current ? ` ${progress.current}` : '');
+    }
+
+    updateStatus(job) {
+        if (job.status === 'running') {
+            this.title.textContent = '处理中...';
+            return;
+        }
+        if (job.status === 'waiting') {
+            this.title.textContent = `等待任务 ${job.waitingFor} 释放目录...`;
+            return;
+        }
+        if (job.status === 'queued') {
+            return;
+        }
+
+        // 任务结束
+        this.source.close();
+        this.cancelButton.remove();
+        this.byteProgress.textContent = '';
+        this.container.classList.remove('running');
+
+        if (job.result && job.result.log && !this.log.textContent) {
+            this.log.textContent = job.result.log;
+        }
+
+        if (job.status === 'succeeded') {
+            this.title.textContent = '处理结果:';
+            this.container.classList.add(job.result && job.result.failed > 0 ? 'error' : 'success');
+        } else if (job.status === 'cancelled') {
+            this.title.textContent = '任务已取消';
+            this.container.classList.add('error');
+        } else {
+            this.title.textContent = '错误:';
+            this.container.classList.add('error');
+            if (job.error) {
+                this.appendLog(job.error + '\n');
+            }
+        }
+    }
+
+    cancel() {
+        this.cancelButton.disabled = true;
+        fetch(`/api/jobs/${encodeURIComponent(this.jobId)}`, { method: 'DELETE' })
+            .then(response => response.json())
+            .then(data => {
+                if (!data.success) {
+                    this.appendLog(`取消失败: ${data.message}\n`);
+                    this.cancelButton.disabled = false;
+                }
+            })
+            .catch(error => {
+                this.appendLog(`取消失败: ${error.message}\n`);
+                this.cancelButton.disabled = false;
+            });
+    }
+}
+
+// 格式化字节数
+function formatBytes(bytes) {
+    const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    let value = bytes;
+    let unit = 0;
+    while (value >= 1024 && unit < units.length - 1) {
+        value /= 1024;
+        unit++;
+    }
+    return `${value.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
+}
+
+// 创建结果容器，替换之前的结果并插入到表单后面
+function createResultContainer(className) {
     const oldResult = document.querySelector('.result-container');
     if (oldResult) {
         oldResult.remove();
     }
 
-    // 创建新的结果容器
     const resultDiv = document.createElement('div');
-    resultDiv.className = `result-container ${data.success ? 'success' : 'error'}`;
+    resultDiv.className = className;
 
-    let resultContent = '';
-    if (data.success) {
-        resultContent = `
-            <h3>处理结果:</h3>
-            <pre>${data.data || data.message}</pre>
-        `;
-    } else {
-        resultContent = `
-            <h3>错误:</h3>
-            <pre>${data.message}</pre>
-        `;
-    }
-
-    resultDiv.innerHTML = resultContent;
-
-    // 插入到表单后面
     const form = document.getElementById('mainForm');
     form.parentNode.insertBefore(resultDiv, form.nextSibling);
-
-    // 滚动到结果位置
     resultDiv.scrollIntoView({ behavior: 'smooth' });
+    return resultDiv;
+}
+
+// 显示处理结果
+function showProcessResult(data) {
+    const resultDiv = createResultContainer(`result-container ${data.success ? 'success' : 'error'}`);
+
+    const title = document.createElement('h3');
+    title.textContent = data.success ? '处理结果:' : '错误:';
+    const pre = document.createElement('pre');
+    pre.textContent = data.success ? (data.data || data.message) : data.message;
+    resultDiv.appendChild(title);
+    resultDiv.appendChild(pre);
+
+    if (data.jobId) {
+        const link = document.createElement('p');
+        link.className = 'job-link';
+        link.innerHTML = `<a href="/jobs/${encodeURIComponent(data.jobId)}">查看任务详情</a>`;
+        resultDiv.appendChild(link);
+    }
 }
 
 // 模式切换功能
@@ -369,13 +558,69 @@ function toggleMode() {
     }
 }
 
+// 根据视频目录所在的挂载点，提示宿主机路径和建议的重定向路径
+let mountHintTimer = null;
+
+function scheduleMountHint() {
+    clearTimeout(mountHintTimer);
+    mountHintTimer = setTimeout(updateMountHint, 400);
+}
+
+function updateMountHint() {
+    const hint = document.getElementById('mountHint');
+    const sourceDir = document.getElementById('sourceDir').value.trim();
+    if (!hint) {
+        return;
+    }
+    if (!sourceDir) {
+        hint.style.display = 'none';
+        return;
+    }
+
+    fetch('/api/mounts?path=' + encodeURIComponent(sourceDir))
+    .then(response => response.json())
+    .then(data => {
+        const suggestion = data.suggestion;
+        if (!data.success || !suggestion || !suggestion.mount) {
+            hint.style.display = 'none';
+            return;
+        }
+
+        hint.textContent = `检测到挂载: ${suggestion.mount.mountPoint} ← 宿主机 ${suggestion.mount.hostPath}`;
+        if (suggestion.guess) {
+            hint.textContent += '（推测：假定磁盘挂载在宿主机根目录，请确认）';
+        }
+        if (suggestion.redirectPath) {
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'link-button';
+            button.textContent = `填入重定向路径 ${suggestion.redirectPath}`;
+            button.addEventListener('click', () => {
+                document.getElementById('redirectPath').value = suggestion.redirectPath;
+            });
+            hint.appendChild(document.createTextNode(' '));
+            hint.appendChild(button);
+        }
+        hint.style.display = 'block';
+    })
+    .catch(() => {
+        hint.style.display = 'none';
+    });
+}
+
 // 页面初始化
 function initializeApp() {
     toggleMode();
 
     // 初始化目录浏览器
-    new DirectoryBrowser('sourceDir', 'sourceDir-browse');
-    new DirectoryBrowser('targetDir', 'targetDir-browse');
+    new DirectoryBrowser('sourceDir', 'sourceDir-browse', 'source');
+    new DirectoryBrowser('targetDir', 'targetDir-browse', 'target');
+
+    // 视频目录变化时更新挂载提示
+    const sourceDir = document.getElementById('sourceDir');
+    sourceDir.addEventListener('input', scheduleMountHint);
+    sourceDir.addEventListener('blur', scheduleMountHint);
+    updateMountHint();
 
     // 添加表单提交事件监听
     const form = document.getElementById('mainForm');